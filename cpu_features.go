@@ -0,0 +1,242 @@
+package ox
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/cpu"
+)
+
+// CPUFeatures describes the CPU of a host, and which optional
+// instruction set extensions it supports.
+type CPUFeatures struct {
+	// Vendor is the CPU vendor, like "GenuineIntel", "AuthenticAMD" or "ARM"
+	Vendor string `json:"vendor,omitempty"`
+	// Model is a human-readable CPU model, like "AMD Ryzen 7 5800X 8-Core Processor"
+	Model string `json:"model,omitempty"`
+	// Flags lists supported features, sorted, using the lowercase
+	// names from /proc/cpuinfo: "sse4_2", "avx2", "asimd", "crc32", etc.
+	Flags []string `json:"flags"`
+}
+
+// featureAliases maps alternate spellings of features
+// to the names used in Flags
+var featureAliases = map[string]string{
+	"sse4.1": "sse4_1",
+	"sse4.2": "sse4_2",
+	"sse41":  "sse4_1",
+	"sse42":  "sse4_2",
+	"sse3":   "pni", // that's what the Linux kernel calls it
+	// on arm64, NEON is called "asimd" (Advanced SIMD)
+	"neon": "asimd",
+}
+
+// armImplementers maps "CPU implementer" values from /proc/cpuinfo to vendor names
+var armImplementers = map[string]string{
+	"0x41": "ARM",
+	"0x42": "Broadcom",
+	"0x43": "Cavium",
+	"0x46": "Fujitsu",
+	"0x48": "HiSilicon",
+	"0x4e": "NVIDIA",
+	"0x50": "APM",
+	"0x51": "Qualcomm",
+	"0x53": "Samsung",
+	"0x56": "Marvell",
+	"0x61": "Apple",
+	"0x69": "Intel",
+	"0xc0": "Ampere",
+}
+
+func normalizeFeature(feature string) string {
+	feature = strings.ToLower(strings.TrimSpace(feature))
+	if alias, ok := featureAliases[feature]; ok {
+		return alias
+	}
+	return feature
+}
+
+// Has returns true if the CPU supports feature. Feature names are
+// case-insensitive, and common aliases are accepted ("sse4.2" for
+// "sse4_2", "neon" for "asimd").
+func (cf CPUFeatures) Has(feature string) bool {
+	feature = normalizeFeature(feature)
+	for _, flag := range cf.Flags {
+		if normalizeFeature(flag) == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Missing returns the features of required that the CPU
+// does not support, or nil if it supports all of them.
+func (cf CPUFeatures) Missing(required ...string) []string {
+	var missing []string
+	for _, feature := range required {
+		if !cf.Has(feature) {
+			missing = append(missing, feature)
+		}
+	}
+	return missing
+}
+
+// ParseCPUInfo parses the contents of /proc/cpuinfo.
+// Only the first processor is taken into account.
+func ParseCPUInfo(r io.Reader) (CPUFeatures, error) {
+	var cf CPUFeatures
+	var boardModel, hardware string
+	flags := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	seenProcessor := false
+	firstProcessorDone := false
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "processor":
+			if seenProcessor {
+				// ARM kernels list global fields (Hardware, Model)
+				// after all processors, so keep going, but ignore
+				// per-processor fields from now on.
+				firstProcessorDone = true
+			}
+			seenProcessor = true
+		case "vendor_id":
+			if cf.Vendor == "" {
+				cf.Vendor = value
+			}
+		case "CPU implementer":
+			if cf.Vendor == "" {
+				cf.Vendor = armImplementers[strings.ToLower(value)]
+			}
+		case "model name":
+			if cf.Model == "" {
+				cf.Model = value
+			}
+		case "Model":
+			// Raspberry Pi and other SBCs
+			boardModel = value
+		case "Hardware":
+			hardware = value
+		case "flags", "Features":
+			if firstProcessorDone {
+				continue
+			}
+			for _, flag := range strings.Fields(value) {
+				flags[strings.ToLower(flag)] = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return CPUFeatures{}, errors.WithStack(err)
+	}
+
+	if cf.Model == "" {
+		cf.Model = boardModel
+	}
+	if cf.Model == "" {
+		cf.Model = hardware
+	}
+	cf.Flags = sortedFlags(flags)
+	return cf, nil
+}
+
+func sortedFlags(flags map[string]bool) []string {
+	res := []string{}
+	for flag := range flags {
+		res = append(res, flag)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// runtimeCPUFlags returns the features golang.org/x/sys/cpu detected,
+// using /proc/cpuinfo names
+func runtimeCPUFlags(goarch string) map[string]bool {
+	switch goarch {
+	case "amd64", "386":
+		return map[string]bool{
+			"sse2":      cpu.X86.HasSSE2,
+			"pni":       cpu.X86.HasSSE3,
+			"ssse3":     cpu.X86.HasSSSE3,
+			"sse4_1":    cpu.X86.HasSSE41,
+			"sse4_2":    cpu.X86.HasSSE42,
+			"popcnt":    cpu.X86.HasPOPCNT,
+			"aes":       cpu.X86.HasAES,
+			"pclmulqdq": cpu.X86.HasPCLMULQDQ,
+			"avx":       cpu.X86.HasAVX,
+			"avx2":      cpu.X86.HasAVX2,
+			"fma":       cpu.X86.HasFMA,
+			"bmi1":      cpu.X86.HasBMI1,
+			"bmi2":      cpu.X86.HasBMI2,
+			"avx512f":   cpu.X86.HasAVX512F,
+			"cx16":      cpu.X86.HasCX16,
+		}
+	case "arm64":
+		return map[string]bool{
+			"fp":      cpu.ARM64.HasFP,
+			"asimd":   cpu.ARM64.HasASIMD,
+			"aes":     cpu.ARM64.HasAES,
+			"pmull":   cpu.ARM64.HasPMULL,
+			"sha1":    cpu.ARM64.HasSHA1,
+			"sha2":    cpu.ARM64.HasSHA2,
+			"crc32":   cpu.ARM64.HasCRC32,
+			"atomics": cpu.ARM64.HasATOMICS,
+			"sve":     cpu.ARM64.HasSVE,
+		}
+	case "arm":
+		return map[string]bool{
+			"vfp":   cpu.ARM.HasVFP,
+			"vfpv3": cpu.ARM.HasVFPv3,
+			"vfpv4": cpu.ARM.HasVFPv4,
+			"neon":  cpu.ARM.HasNEON,
+			"crc32": cpu.ARM.HasCRC32,
+			"aes":   cpu.ARM.HasAES,
+		}
+	}
+	return nil
+}
+
+var (
+	currentCPUFeaturesOnce sync.Once
+	currentCPUFeatures     CPUFeatures
+)
+
+// CurrentCPUFeatures returns the features of the host CPU, combining
+// /proc/cpuinfo (where available) and what golang.org/x/sys/cpu detects.
+func CurrentCPUFeatures() CPUFeatures {
+	currentCPUFeaturesOnce.Do(func() {
+		var cf CPUFeatures
+		if contents, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+			cf, _ = ParseCPUInfo(bytes.NewReader(contents))
+		}
+
+		flags := make(map[string]bool)
+		for _, flag := range cf.Flags {
+			flags[flag] = true
+		}
+		for flag, has := range runtimeCPUFlags(runtime.GOARCH) {
+			if has {
+				flags[flag] = true
+			}
+		}
+		cf.Flags = sortedFlags(flags)
+		currentCPUFeatures = cf
+	})
+	return currentCPUFeatures
+}
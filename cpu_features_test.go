@@ -0,0 +1,97 @@
+package ox_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseCPUInfoFixture(t *testing.T, name string) ox.CPUFeatures {
+	f, err := os.Open(filepath.Join("testdata", "cpuinfo", name))
+	must(err)
+	defer f.Close()
+
+	cf, err := ox.ParseCPUInfo(f)
+	must(err)
+	return cf
+}
+
+func TestParseCPUInfo(t *testing.T) {
+	tests := []struct {
+		fixture string
+		vendor  string
+		model   string
+		has     []string
+		missing []string
+	}{
+		{
+			fixture: "intel-core2.txt",
+			vendor:  "GenuineIntel",
+			model:   "Intel(R) Core(TM)2 Duo CPU     E8400  @ 3.00GHz",
+			has:     []string{"sse2", "sse3", "ssse3", "sse4.1", "SSE4_1", "lm"},
+			missing: []string{"sse4.2", "avx", "avx2"},
+		},
+		{
+			fixture: "amd-ryzen.txt",
+			vendor:  "AuthenticAMD",
+			model:   "AMD Ryzen 7 5800X 8-Core Processor",
+			has:     []string{"sse4_2", "sse4.2", "avx", "avx2", "aes"},
+			missing: []string{"avx512f"},
+		},
+		{
+			fixture: "raspberrypi4-armhf.txt",
+			vendor:  "ARM",
+			model:   "ARMv7 Processor rev 3 (v7l)",
+			has:     []string{"neon", "crc32", "vfpv4"},
+			missing: []string{"aes", "sse2"},
+		},
+		{
+			fixture: "graviton2-arm64.txt",
+			vendor:  "ARM",
+			model:   "",
+			has:     []string{"neon", "asimd", "crc32", "aes", "atomics"},
+			missing: []string{"sve"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.fixture, func(t *testing.T) {
+			cf := parseCPUInfoFixture(t, tc.fixture)
+			assert.Equal(t, tc.vendor, cf.Vendor)
+			assert.Equal(t, tc.model, cf.Model)
+			assert.Empty(t, cf.Missing(tc.has...))
+			assert.Equal(t, tc.missing, cf.Missing(append(tc.has, tc.missing...)...))
+		})
+	}
+}
+
+func TestParseCPUInfo_BoardModel(t *testing.T) {
+	// older ARM kernels don't have "model name", fall back to the board
+	cf, err := ox.ParseCPUInfo(strings.NewReader(`processor	: 0
+Features	: swp half thumb fastmult vfp edsp java tls
+CPU implementer	: 0x41
+
+Hardware	: BCM2835
+Model		: Raspberry Pi Model B Rev 2
+`))
+	must(err)
+	assert.Equal(t, "Raspberry Pi Model B Rev 2", cf.Model)
+	assert.True(t, cf.Has("vfp"))
+	assert.False(t, cf.Has("neon"))
+}
+
+func TestCurrentCPUFeatures(t *testing.T) {
+	cf := ox.CurrentCPUFeatures()
+	switch runtime.GOARCH {
+	case "amd64":
+		// SSE2 is part of the x86-64 baseline
+		assert.True(t, cf.Has("sse2"))
+	case "arm64":
+		assert.True(t, cf.Has("asimd"))
+	}
+}
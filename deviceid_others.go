@@ -0,0 +1,24 @@
+//go:build !windows
+
+package ox
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// DeviceID returns an identifier for the filesystem path is on.
+// Paths on the same filesystem have the same device ID.
+func DeviceID(path string) (uint64, error) {
+	stats, err := os.Stat(path)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	st, ok := stats.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.Errorf("DeviceID: no stat information for %s", path)
+	}
+	return uint64(st.Dev), nil
+}
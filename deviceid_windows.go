@@ -0,0 +1,33 @@
+//go:build windows
+
+package ox
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// DeviceID returns an identifier for the volume path is on,
+// which is its serial number. Paths on the same volume have
+// the same device ID.
+func DeviceID(path string) (uint64, error) {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	volumePath := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumePathName(path16, &volumePath[0], uint32(len(volumePath)))
+	if err != nil {
+		return 0, errors.WithStack(&os.PathError{Op: "GetVolumePathName", Path: path, Err: err})
+	}
+
+	var serial uint32
+	err = windows.GetVolumeInformation(&volumePath[0], nil, 0, &serial, nil, nil, nil, 0)
+	if err != nil {
+		return 0, errors.WithStack(&os.PathError{Op: "GetVolumeInformation", Path: path, Err: err})
+	}
+	return uint64(serial), nil
+}
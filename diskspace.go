@@ -0,0 +1,67 @@
+package ox
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// DiskSpace describes the space on the filesystem a path is on, in bytes
+type DiskSpace struct {
+	// Free is the number of free bytes, including any reserved
+	// for the superuser
+	Free uint64
+	// Available is the number of free bytes the current user can use
+	Available uint64
+	// Total is the size of the filesystem
+	Total uint64
+}
+
+// ErrInsufficientSpace is returned (wrapped in an InsufficientSpaceError)
+// when there isn't enough disk space for an operation
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// InsufficientSpaceError is returned when there isn't enough disk
+// space for an operation. It matches ErrInsufficientSpace with errors.Is
+type InsufficientSpaceError struct {
+	// Path is the file that needed space
+	Path string
+	// Required is the number of bytes the operation needed
+	Required uint64
+	// Available is the number of bytes that were available
+	Available uint64
+}
+
+var _ error = (*InsufficientSpaceError)(nil)
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("%v: need %v bytes for %s, only %v available", ErrInsufficientSpace, e.Required, e.Path, e.Available)
+}
+
+// Is makes errors.Is(err, ErrInsufficientSpace) work
+func (e *InsufficientSpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// checkDiskSpace returns an InsufficientSpaceError if there's less than
+// `required` bytes available on f's filesystem. It's best-effort: if the
+// available space can't be determined, it returns nil and lets the
+// caller try anyway.
+func checkDiskSpace(f *os.File, required uint64) error {
+	ds, err := diskFreeSpaceOfFile(f)
+	if err != nil {
+		// some filesystems and platforms don't support statfs, that
+		// shouldn't prevent writing to them: the write itself will
+		// fail with ENOSPC if the space really isn't there
+		return nil
+	}
+	if required > ds.Available {
+		return errors.WithStack(&InsufficientSpaceError{
+			Path:      f.Name(),
+			Required:  required,
+			Available: ds.Available,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ox
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// DiskFreeSpace is not supported on this platform
+func DiskFreeSpace(path string) (DiskSpace, error) {
+	return DiskSpace{}, errors.Errorf("DiskFreeSpace: not supported on %s", runtime.GOOS)
+}
+
+func diskFreeSpaceOfFile(f *os.File) (DiskSpace, error) {
+	return DiskFreeSpace(f.Name())
+}
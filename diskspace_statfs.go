@@ -0,0 +1,45 @@
+//go:build linux || darwin || freebsd
+
+package ox
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// DiskFreeSpace returns the free, available and total space
+// of the filesystem path is on.
+func DiskFreeSpace(path string) (DiskSpace, error) {
+	var st unix.Statfs_t
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		return DiskSpace{}, errors.WithStack(&os.PathError{Op: "statfs", Path: path, Err: err})
+	}
+	return diskSpaceFromStatfs(&st), nil
+}
+
+func diskFreeSpaceOfFile(f *os.File) (DiskSpace, error) {
+	var st unix.Statfs_t
+	err := unix.Fstatfs(int(f.Fd()), &st)
+	if err != nil {
+		return DiskSpace{}, errors.WithStack(&os.PathError{Op: "fstatfs", Path: f.Name(), Err: err})
+	}
+	return diskSpaceFromStatfs(&st), nil
+}
+
+func diskSpaceFromStatfs(st *unix.Statfs_t) DiskSpace {
+	bsize := statfsBlockSize(st)
+	// Bavail is signed on some BSDs, it goes negative when
+	// non-root users have eaten into the reserved blocks
+	avail := int64(st.Bavail)
+	if avail < 0 {
+		avail = 0
+	}
+	return DiskSpace{
+		Free:      uint64(st.Bfree) * bsize,
+		Available: uint64(avail) * bsize,
+		Total:     uint64(st.Blocks) * bsize,
+	}
+}
@@ -0,0 +1,11 @@
+//go:build darwin || freebsd
+
+package ox
+
+import "golang.org/x/sys/unix"
+
+// statfsBlockSize returns the unit block counts are in, which
+// is f_bsize on BSDs, since their statfs has no f_frsize
+func statfsBlockSize(st *unix.Statfs_t) uint64 {
+	return uint64(st.Bsize)
+}
@@ -0,0 +1,13 @@
+package ox
+
+import "golang.org/x/sys/unix"
+
+// statfsBlockSize returns the unit block counts are in, which is
+// f_frsize on Linux. f_bsize is only the preferred I/O size, and
+// differs from it on some filesystems (NFS, FUSE, etc.)
+func statfsBlockSize(st *unix.Statfs_t) uint64 {
+	if st.Frsize > 0 {
+		return uint64(st.Frsize)
+	}
+	return uint64(st.Bsize)
+}
@@ -0,0 +1,51 @@
+package ox_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiskFreeSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	ds, err := ox.DiskFreeSpace(os.TempDir())
+	must(err)
+	assert.True(ds.Total > 0)
+	assert.True(ds.Free <= ds.Total)
+	assert.True(ds.Available <= ds.Free)
+
+	_, err = ox.DiskFreeSpace("/this/path/does/not/exist")
+	assert.Error(err)
+}
+
+func Test_PreallocateInsufficientSpace(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	ds, err := ox.DiskFreeSpace(os.TempDir())
+	must(err)
+
+	size := int64(ds.Available) + 1024*1024*1024
+	err = ox.Preallocate(f, size)
+	assert.Error(err)
+	assert.True(errors.Is(err, ox.ErrInsufficientSpace))
+
+	var ise *ox.InsufficientSpaceError
+	if assert.True(errors.As(err, &ise)) {
+		assert.EqualValues(size, ise.Required)
+		assert.Equal(f.Name(), ise.Path)
+	}
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(0, s.Size(), "nothing should have been written")
+}
@@ -0,0 +1,40 @@
+//go:build windows
+
+package ox
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/itchio/ox/syscallex"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// DiskFreeSpace returns the free, available and total space
+// of the volume path is on.
+func DiskFreeSpace(path string) (DiskSpace, error) {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return DiskSpace{}, errors.WithStack(err)
+	}
+
+	dfs, err := syscallex.GetDiskFreeSpaceEx(path16)
+	if err != nil {
+		return DiskSpace{}, errors.WithStack(&os.PathError{Op: "GetDiskFreeSpaceEx", Path: path, Err: err})
+	}
+	return DiskSpace{
+		Free:      dfs.TotalNumberOfFreeBytes,
+		Available: dfs.FreeBytesAvailable,
+		Total:     dfs.TotalNumberOfBytes,
+	}, nil
+}
+
+func diskFreeSpaceOfFile(f *os.File) (DiskSpace, error) {
+	// GetDiskFreeSpaceEx wants a directory
+	dir, err := filepath.Abs(filepath.Dir(f.Name()))
+	if err != nil {
+		return DiskSpace{}, errors.WithStack(err)
+	}
+	return DiskFreeSpace(dir)
+}
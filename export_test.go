@@ -0,0 +1,13 @@
+package ox
+
+import "os"
+
+// SetZeroFillWriteAt replaces how zero-fill writes chunks,
+// and returns a function that restores the original.
+func SetZeroFillWriteAt(writeAt func(f *os.File, zeros []byte, offset int64) (int, error)) func() {
+	original := zeroFillWriteAt
+	zeroFillWriteAt = writeAt
+	return func() {
+		zeroFillWriteAt = original
+	}
+}
@@ -0,0 +1,214 @@
+package ox
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotSupported is returned (wrapped in a NotSupportedError) when
+// the filesystem or platform doesn't support an operation
+var ErrNotSupported = errors.New("not supported")
+
+// NotSupportedError is returned when the filesystem or platform
+// doesn't support an operation. It matches ErrNotSupported with errors.Is
+type NotSupportedError struct {
+	// Op is the operation, like "fiemap" or "punch hole"
+	Op   string
+	Path string
+	// Err is the underlying error, if any
+	Err error
+}
+
+var _ error = (*NotSupportedError)(nil)
+
+func (e *NotSupportedError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v by this filesystem or platform", e.Op, e.Path, ErrNotSupported)
+	if e.Err != nil {
+		msg += fmt.Sprintf(" (%v)", e.Err)
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrNotSupported) work
+func (e *NotSupportedError) Is(target error) bool {
+	return target == ErrNotSupported
+}
+
+func (e *NotSupportedError) Unwrap() error {
+	return e.Err
+}
+
+// ExtentMethod is how the extents of a file are queried
+type ExtentMethod string
+
+const (
+	// ExtentAuto uses FIEMAP, then falls back to SEEK_DATA/SEEK_HOLE
+	ExtentAuto ExtentMethod = "auto"
+	// ExtentFiemap uses the FS_IOC_FIEMAP ioctl (Linux only), which
+	// tells allocated-but-unwritten extents (from fallocate) apart
+	ExtentFiemap ExtentMethod = "fiemap"
+	// ExtentSeek uses lseek(2) with SEEK_DATA and SEEK_HOLE. Some
+	// filesystems (like ext4) report unwritten extents as holes.
+	ExtentSeek ExtentMethod = "seek"
+)
+
+// Extent is a range of a file
+type Extent struct {
+	Offset int64
+	Length int64
+	// Hole is true if no blocks are allocated for the range
+	Hole bool
+	// Unwritten is true for ranges that are allocated but read
+	// as zeros, which is what fallocate creates. Only FIEMAP
+	// reports it.
+	Unwritten bool
+}
+
+// ExtentMap describes which parts of a file are allocated
+type ExtentMap struct {
+	// Method is the method that was actually used (never ExtentAuto)
+	Method ExtentMethod
+	// Size is the size of the file
+	Size int64
+	// Extents cover the whole file, in order, holes included
+	Extents []Extent
+}
+
+// Allocated returns how many bytes of the file have blocks allocated
+func (em ExtentMap) Allocated() int64 {
+	var res int64
+	for _, e := range em.Extents {
+		if !e.Hole {
+			res += e.Length
+		}
+	}
+	return res
+}
+
+// FullyAllocated returns true if the file has no holes
+func (em ExtentMap) FullyAllocated() bool {
+	return em.Allocated() == em.Size
+}
+
+// FileExtents returns the data and hole extents of f, using
+// FIEMAP if possible, and SEEK_DATA/SEEK_HOLE otherwise. It returns
+// a NotSupportedError if neither works.
+func FileExtents(f *os.File) (*ExtentMap, error) {
+	return FileExtentsWithMethod(f, ExtentAuto)
+}
+
+// FileExtentsWithMethod is like FileExtents, but only uses the given method
+func FileExtentsWithMethod(f *os.File, method ExtentMethod) (*ExtentMap, error) {
+	stats, err := f.Stat()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	em := &ExtentMap{Size: stats.Size()}
+	if em.Size == 0 {
+		em.Method = method
+		if method == ExtentAuto {
+			em.Method = ExtentSeek
+		}
+		return em, nil
+	}
+
+	var data []Extent
+	switch method {
+	case ExtentAuto:
+		em.Method = ExtentFiemap
+		data, err = fiemapExtents(f, em.Size)
+		if errors.Is(err, ErrNotSupported) {
+			em.Method = ExtentSeek
+			data, err = seekExtents(f, em.Size)
+		}
+	case ExtentFiemap:
+		em.Method = ExtentFiemap
+		data, err = fiemapExtents(f, em.Size)
+	case ExtentSeek:
+		em.Method = ExtentSeek
+		data, err = seekExtents(f, em.Size)
+	default:
+		return nil, errors.Errorf("unknown extent method %q", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	em.Extents = withHoles(data, em.Size)
+	return em, nil
+}
+
+// withHoles clips sorted data extents to [0, size), merges
+// adjacent ones, and adds holes between them.
+func withHoles(data []Extent, size int64) []Extent {
+	var res []Extent
+	var offset int64
+
+	for _, e := range data {
+		start, end := e.Offset, e.Offset+e.Length
+		if start < offset {
+			start = offset
+		}
+		if end > size {
+			end = size
+		}
+		if start >= end {
+			continue
+		}
+
+		if start > offset {
+			res = append(res, Extent{Offset: offset, Length: start - offset, Hole: true})
+		}
+		if n := len(res); n > 0 && !res[n-1].Hole && res[n-1].Unwritten == e.Unwritten && res[n-1].Offset+res[n-1].Length == start {
+			res[n-1].Length += end - start
+		} else {
+			res = append(res, Extent{Offset: start, Length: end - start, Unwritten: e.Unwritten})
+		}
+		offset = end
+	}
+
+	if offset < size {
+		res = append(res, Extent{Offset: offset, Length: size - offset, Hole: true})
+	}
+	return res
+}
+
+// ZeroRange makes length bytes of f, starting at offset, read as
+// zeros. It punches a hole if possible, and otherwise writes zeros.
+// punched tells which of the two happened.
+func ZeroRange(f *os.File, offset int64, length int64) (punched bool, err error) {
+	err = PunchHole(f, offset, length)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, ErrNotSupported) {
+		return false, err
+	}
+
+	stats, err := f.Stat()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	// like punching a hole, never grow the file
+	if offset+length > stats.Size() {
+		length = stats.Size() - offset
+	}
+
+	zeros := zeroBuffer()
+	for length > 0 {
+		n := int64(len(zeros))
+		if length < n {
+			n = length
+		}
+		_, err = f.WriteAt(zeros[:n], offset)
+		if err != nil {
+			return false, errors.Wrapf(err, "while zeroing %v bytes at %v", length, offset)
+		}
+		offset += n
+		length -= n
+	}
+	return false, nil
+}
@@ -0,0 +1,25 @@
+//go:build darwin || freebsd
+
+package ox
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func fiemapExtents(f *os.File, size int64) ([]Extent, error) {
+	return nil, errors.WithStack(&NotSupportedError{Op: "fiemap", Path: f.Name()})
+}
+
+// PunchHole deallocates length bytes of f, starting at offset, without
+// changing its size. It's only implemented on Linux, elsewhere it
+// returns a NotSupportedError, see ZeroRange for a fallback.
+func PunchHole(f *os.File, offset int64, length int64) error {
+	return errors.WithStack(&NotSupportedError{Op: "punch hole", Path: f.Name(), Err: errors.Errorf("not implemented on %s", runtime.GOOS)})
+}
+
+func fallocateKeepSize(f *os.File, offset int64, length int64) error {
+	return errors.WithStack(&NotSupportedError{Op: "fallocate keep size", Path: f.Name(), Err: errors.Errorf("not implemented on %s", runtime.GOOS)})
+}
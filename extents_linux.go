@@ -0,0 +1,114 @@
+package ox
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// _IOWR('f', 11, struct fiemap), the same on every architecture
+	fsIocFiemap = 0xC020660B
+
+	fiemapFlagSync = 0x1
+
+	fiemapExtentLast      = 0x1
+	fiemapExtentUnwritten = 0x800
+
+	// how many extents to ask for with each ioctl
+	fiemapBatchSize = 64
+)
+
+// cf. linux/fiemap.h
+type fiemapExtent struct {
+	Logical    uint64
+	Physical   uint64
+	Length     uint64
+	reserved64 [2]uint64
+	Flags      uint32
+	reserved   [3]uint32
+}
+
+type fiemap struct {
+	Start         uint64
+	Length        uint64
+	Flags         uint32
+	MappedExtents uint32
+	ExtentCount   uint32
+	reserved      uint32
+	Extents       [fiemapBatchSize]fiemapExtent
+}
+
+func fiemapExtents(f *os.File, size int64) ([]Extent, error) {
+	var res []Extent
+	var fm fiemap
+
+	start := uint64(0)
+	for start < uint64(size) {
+		fm = fiemap{
+			Start:       start,
+			Length:      uint64(size) - start,
+			Flags:       fiemapFlagSync,
+			ExtentCount: fiemapBatchSize,
+		}
+
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), fsIocFiemap, uintptr(unsafe.Pointer(&fm)))
+		if errno != 0 {
+			if isNotSupportedErrno(errno) {
+				return nil, errors.WithStack(&NotSupportedError{Op: "fiemap", Path: f.Name(), Err: errno})
+			}
+			return nil, errors.WithStack(&os.PathError{Op: "fiemap", Path: f.Name(), Err: errno})
+		}
+
+		if fm.MappedExtents == 0 {
+			break
+		}
+
+		last := false
+		for _, fe := range fm.Extents[:fm.MappedExtents] {
+			res = append(res, Extent{
+				Offset:    int64(fe.Logical),
+				Length:    int64(fe.Length),
+				Unwritten: fe.Flags&fiemapExtentUnwritten != 0,
+			})
+			start = fe.Logical + fe.Length
+			if fe.Flags&fiemapExtentLast != 0 {
+				last = true
+			}
+		}
+		if last {
+			break
+		}
+	}
+	return res, nil
+}
+
+// PunchHole deallocates length bytes of f, starting at offset, without
+// changing its size. The range reads as zeros afterwards. It returns a
+// NotSupportedError if the filesystem can't do it, see ZeroRange for
+// a fallback.
+func PunchHole(f *os.File, offset int64, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if err != nil {
+		if isNotSupportedErrno(err) {
+			return errors.WithStack(&NotSupportedError{Op: "punch hole", Path: f.Name(), Err: err})
+		}
+		return errors.WithStack(&os.PathError{Op: "punch hole", Path: f.Name(), Err: err})
+	}
+	return nil
+}
+
+// fallocateKeepSize allocates blocks without changing the file
+// size, which is how blocks past the end of a file are allocated.
+func fallocateKeepSize(f *os.File, offset int64, length int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, offset, length)
+	if err != nil {
+		if isNotSupportedErrno(err) {
+			return errors.WithStack(&NotSupportedError{Op: "fallocate keep size", Path: f.Name(), Err: err})
+		}
+		return errors.WithStack(&os.PathError{Op: "fallocate keep size", Path: f.Name(), Err: err})
+	}
+	return nil
+}
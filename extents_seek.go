@@ -0,0 +1,58 @@
+//go:build linux || darwin || freebsd
+
+package ox
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func seekExtents(f *os.File, size int64) ([]Extent, error) {
+	fd := int(f.Fd())
+
+	// leave the file offset where we found it
+	pos, err := unix.Seek(fd, 0, io.SeekCurrent)
+	if err != nil {
+		return nil, errors.WithStack(&os.PathError{Op: "seek", Path: f.Name(), Err: err})
+	}
+	defer unix.Seek(fd, pos, io.SeekStart)
+
+	var res []Extent
+	var offset int64
+	for offset < size {
+		data, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if err == unix.ENXIO {
+			// no more data, the rest is a hole
+			break
+		}
+		if err != nil {
+			// EINVAL means SEEK_DATA isn't known to the kernel
+			if err == unix.EINVAL || isNotSupportedErrno(err) {
+				return nil, errors.WithStack(&NotSupportedError{Op: "seek data", Path: f.Name(), Err: err})
+			}
+			return nil, errors.WithStack(&os.PathError{Op: "seek data", Path: f.Name(), Err: err})
+		}
+
+		hole, err := unix.Seek(fd, data, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, errors.WithStack(&os.PathError{Op: "seek hole", Path: f.Name(), Err: err})
+		}
+
+		res = append(res, Extent{Offset: data, Length: hole - data})
+		offset = hole
+	}
+	return res, nil
+}
+
+// isNotSupportedErrno returns true for errors filesystems
+// return for operations they don't implement
+func isNotSupportedErrno(err error) bool {
+	switch err {
+	case unix.EOPNOTSUPP, unix.ENOTTY, unix.ENOSYS:
+		return true
+	}
+	return false
+}
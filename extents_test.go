@@ -0,0 +1,144 @@
+package ox_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+const mib = 1024 * 1024
+
+func Test_FileExtents(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	em, err := ox.FileExtents(f)
+	must(err)
+	assert.EqualValues(0, em.Size)
+	assert.Empty(em.Extents)
+
+	// data, hole, data
+	data := bytes.Repeat([]byte{0xaa}, mib)
+	_, err = f.WriteAt(data, 0)
+	must(err)
+	_, err = f.WriteAt(data, 3*mib)
+	must(err)
+
+	em, err = ox.FileExtents(f)
+	if errors.Is(err, ox.ErrNotSupported) {
+		t.Skipf("extents not supported here: %v", err)
+	}
+	must(err)
+	t.Logf("method %s, extents %+v", em.Method, em.Extents)
+	assert.NotEqual(ox.ExtentAuto, em.Method)
+	assert.EqualValues(4*mib, em.Size)
+
+	var total int64
+	for _, e := range em.Extents {
+		total += e.Length
+	}
+	assert.EqualValues(em.Size, total, "extents should cover the whole file")
+	// filesystems may allocate more than was written (block
+	// size, speculative preallocation), but not the whole hole
+	assert.False(em.Extents[0].Hole)
+	assert.False(em.FullyAllocated())
+	assert.True(em.Allocated() >= 2*mib, "allocated %v", em.Allocated())
+
+	if runtime.GOOS != "windows" {
+		em, err = ox.FileExtentsWithMethod(f, ox.ExtentSeek)
+		must(err)
+		assert.Equal(ox.ExtentSeek, em.Method)
+		assert.False(em.FullyAllocated())
+		assert.True(em.Allocated() >= 2*mib, "allocated %v", em.Allocated())
+	}
+}
+
+func Test_FileExtentsPreallocated(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("FIEMAP is Linux-only")
+	}
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = ox.PreallocateWithOptions(f, 2*mib, ox.PreallocateOptions{Strategy: ox.PreallocateFallocate})
+	if err != nil {
+		t.Skipf("fallocate not supported here: %v", err)
+	}
+
+	em, err := ox.FileExtentsWithMethod(f, ox.ExtentFiemap)
+	if errors.Is(err, ox.ErrNotSupported) {
+		t.Skipf("FIEMAP not supported here: %v", err)
+	}
+	must(err)
+	assert.True(em.FullyAllocated())
+	assert.True(em.Extents[0].Unwritten)
+
+	sparse, err := ioutil.TempFile("", "")
+	must(err)
+	defer sparse.Close()
+	defer os.Remove(sparse.Name())
+
+	_, err = ox.PreallocateWithOptions(sparse, 2*mib, ox.PreallocateOptions{Strategy: ox.PreallocateSparse})
+	must(err)
+	em, err = ox.FileExtentsWithMethod(sparse, ox.ExtentFiemap)
+	must(err)
+	assert.EqualValues(0, em.Allocated())
+	assert.Equal([]ox.Extent{{Offset: 0, Length: 2 * mib, Hole: true}}, em.Extents)
+}
+
+func Test_PunchHole(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	data := bytes.Repeat([]byte{0xaa}, 3*mib)
+	_, err = f.WriteAt(data, 0)
+	must(err)
+
+	err = ox.PunchHole(f, mib, mib)
+	if errors.Is(err, ox.ErrNotSupported) {
+		var nse *ox.NotSupportedError
+		assert.True(errors.As(err, &nse))
+		assert.Equal("punch hole", nse.Op)
+	} else {
+		must(err)
+		em, err := ox.FileExtents(f)
+		if err == nil {
+			assert.False(em.FullyAllocated())
+			assert.True(em.Allocated() >= 2*mib, "allocated %v", em.Allocated())
+		}
+	}
+
+	// either way, ZeroRange works
+	punched, err := ox.ZeroRange(f, mib, mib)
+	must(err)
+	t.Logf("punched: %v", punched)
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(3*mib, s.Size(), "size should not change")
+
+	buf := make([]byte, 3*mib)
+	_, err = f.ReadAt(buf, 0)
+	must(err)
+	assert.Equal(data[:mib], buf[:mib])
+	assert.Equal(make([]byte, mib), buf[mib:2*mib])
+	assert.Equal(data[2*mib:], buf[2*mib:])
+}
@@ -0,0 +1,189 @@
+package ox
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Filesystem describes the filesystem a path is on
+type Filesystem struct {
+	// Type is the filesystem type, lowercase, like "ext4", "btrfs",
+	// "zfs", "ntfs", "fuseblk" (NTFS-3G), "apfs" or "unknown"
+	Type string
+	// Device is the device ID, as returned by DeviceID
+	Device uint64
+	// MountPoint is where the filesystem is mounted, if known
+	MountPoint string
+	// Source is what is mounted, usually a device like "/dev/sda1", if known
+	Source string
+	// MountOptions are options as found in mountinfo, like "noatime"
+	MountOptions []string
+
+	ReadOnly bool
+	NoExec   bool
+	NoSuid   bool
+
+	Capabilities FilesystemCapabilities
+}
+
+// FilesystemCapabilities are what a filesystem supports. Unless
+// Probed is true, only MaxNameLength may be set.
+type FilesystemCapabilities struct {
+	// Probed is true if the capabilities were checked by creating
+	// files, which only happens on writable filesystems
+	Probed bool
+
+	// Fallocate is true if space can be reserved without writing zeros
+	Fallocate bool
+	// Symlinks is true if symbolic links can be created
+	Symlinks bool
+	// ExecBit is true if the executable bit is stored. See
+	// Filesystem.NoExec for whether files can actually be executed
+	ExecBit bool
+	// Xattrs is true if extended attributes can be set
+	Xattrs bool
+	// CaseSensitive is true if "a" and "A" are different files
+	CaseSensitive bool
+	// MaxNameLength is the maximum length of a file name, in bytes
+	MaxNameLength int
+}
+
+// PreallocateStrategy returns the best way to preallocate
+// files on the filesystem.
+func (fs Filesystem) PreallocateStrategy() PreallocateStrategy {
+	if fs.Capabilities.Probed && !fs.Capabilities.Fallocate {
+		return PreallocateZeroFill
+	}
+	return PreallocateAuto
+}
+
+var (
+	capabilitiesCacheLock sync.Mutex
+	capabilitiesCache     = make(map[uint64]FilesystemCapabilities)
+)
+
+// FilesystemInfo returns the type, mount options and capabilities of
+// the filesystem path is on. path doesn't need to exist yet.
+//
+// Mount information is looked up on every call, since the same
+// filesystem can be mounted in several places with different options.
+// Capabilities are probed by creating a temporary directory, in
+// os.TempDir() if it's on the same filesystem, or next to path
+// otherwise, so they're cached per device.
+func FilesystemInfo(path string) (Filesystem, error) {
+	existing, err := existingAncestor(path)
+	if err != nil {
+		return Filesystem{}, err
+	}
+
+	device, err := DeviceID(existing)
+	if err != nil {
+		return Filesystem{}, err
+	}
+
+	fs := Filesystem{Type: "unknown", Device: device}
+	err = detectFilesystem(existing, &fs)
+	if err != nil {
+		return Filesystem{}, err
+	}
+	maxNameLength := fs.Capabilities.MaxNameLength
+
+	capabilitiesCacheLock.Lock()
+	caps, ok := capabilitiesCache[device]
+	capabilitiesCacheLock.Unlock()
+
+	if !ok {
+		if dir, ok := probeDir(existing, device, fs.ReadOnly); ok {
+			// not being able to probe isn't an error, the
+			// directory might just not be writable
+			_ = probeCapabilities(dir, &caps)
+		}
+		if caps.Probed {
+			capabilitiesCacheLock.Lock()
+			capabilitiesCache[device] = caps
+			capabilitiesCacheLock.Unlock()
+		}
+	}
+
+	caps.MaxNameLength = maxNameLength
+	fs.Capabilities = caps
+	return fs, nil
+}
+
+// ClearFilesystemCache forgets the capabilities FilesystemInfo probed,
+// for example after drives have been mounted or unmounted.
+func ClearFilesystemCache() {
+	capabilitiesCacheLock.Lock()
+	defer capabilitiesCacheLock.Unlock()
+	capabilitiesCache = make(map[uint64]FilesystemCapabilities)
+}
+
+// probeDir returns where to probe the capabilities of the filesystem
+// path is on: the temporary directory if it's on the same device,
+// so as not to litter user directories, or else the directory path
+// is in, unless it's mounted read-only.
+func probeDir(path string, device uint64, readOnly bool) (string, bool) {
+	tempDir := os.TempDir()
+	if tempDevice, err := DeviceID(tempDir); err == nil && tempDevice == device {
+		return tempDir, true
+	}
+
+	if readOnly {
+		return "", false
+	}
+	if stats, err := os.Stat(path); err == nil && !stats.IsDir() {
+		return filepath.Dir(path), true
+	}
+	return path, true
+}
+
+// probeCapabilities creates a temporary directory in dir,
+// and tries various things in it.
+func probeCapabilities(dir string, caps *FilesystemCapabilities) error {
+	probeDir, err := os.MkdirTemp(dir, ".ox-probe-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(probeDir)
+
+	filePath := filepath.Join(probeDir, "oxProbe")
+	f, err := os.Create(filePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	caps.Fallocate = probeFallocate(f)
+	f.Close()
+
+	caps.Symlinks = os.Symlink(filePath, filepath.Join(probeDir, "link")) == nil
+	caps.ExecBit = probeExecBit(filePath)
+	caps.Xattrs = probeXattrs(filePath)
+
+	_, err = os.Stat(filepath.Join(probeDir, "OXPROBE"))
+	caps.CaseSensitive = os.IsNotExist(err)
+
+	caps.Probed = true
+	return nil
+}
+
+// probeExecBit returns true if the executable bit of path can be
+// both cleared and set. Filesystems like vfat or ntfs with fmask or
+// umask options report the same mode for every file, and some of
+// them silently ignore chmod, so the mode is checked after each one.
+func probeExecBit(path string) bool {
+	for _, mode := range []os.FileMode{0644, 0755} {
+		if err := os.Chmod(path, mode); err != nil {
+			return false
+		}
+		stats, err := os.Stat(path)
+		if err != nil {
+			return false
+		}
+		if stats.Mode()&0100 != mode&0100 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+//go:build darwin || freebsd
+
+package ox
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+func detectFilesystem(path string, fs *Filesystem) error {
+	var st unix.Statfs_t
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		return errors.WithStack(&os.PathError{Op: "statfs", Path: path, Err: err})
+	}
+
+	fs.Type = strings.ToLower(unix.ByteSliceToString(st.Fstypename[:]))
+	fs.MountPoint = unix.ByteSliceToString(st.Mntonname[:])
+	fs.Source = unix.ByteSliceToString(st.Mntfromname[:])
+	fs.ReadOnly = uint64(st.Flags)&unix.MNT_RDONLY != 0
+	fs.NoExec = uint64(st.Flags)&unix.MNT_NOEXEC != 0
+	fs.NoSuid = uint64(st.Flags)&unix.MNT_NOSUID != 0
+	// what almost every filesystem supports, statfs doesn't say
+	fs.Capabilities.MaxNameLength = 255
+	return nil
+}
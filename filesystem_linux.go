@@ -0,0 +1,62 @@
+package ox
+
+import (
+	"os"
+
+	"github.com/itchio/ox/linox"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// filesystemMagics maps statfs(2) f_type values to filesystem names
+var filesystemMagics = map[uint32]string{
+	unix.EXT4_SUPER_MAGIC:      "ext4", // also ext2 and ext3
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	unix.XFS_SUPER_MAGIC:       "xfs",
+	unix.F2FS_SUPER_MAGIC:      "f2fs",
+	unix.BCACHEFS_SUPER_MAGIC:  "bcachefs",
+	unix.REISERFS_SUPER_MAGIC:  "reiserfs",
+	0x2fc12fc1:                 "zfs",
+	0x5346544e:                 "ntfs",
+	0x7366746e:                 "ntfs3",
+	unix.FUSE_SUPER_MAGIC:      "fuse",
+	unix.MSDOS_SUPER_MAGIC:     "vfat",
+	unix.EXFAT_SUPER_MAGIC:     "exfat",
+	unix.ECRYPTFS_SUPER_MAGIC:  "ecryptfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlay",
+	unix.TMPFS_MAGIC:           "tmpfs",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.CIFS_SUPER_MAGIC:      "cifs",
+	unix.SMB2_SUPER_MAGIC:      "smb2",
+	unix.V9FS_MAGIC:            "9p",
+	unix.SQUASHFS_MAGIC:        "squashfs",
+	unix.ISOFS_SUPER_MAGIC:     "iso9660",
+	unix.UDF_SUPER_MAGIC:       "udf",
+	0x482b:                     "hfsplus",
+}
+
+func detectFilesystem(path string, fs *Filesystem) error {
+	var st unix.Statfs_t
+	err := unix.Statfs(path, &st)
+	if err != nil {
+		return errors.WithStack(&os.PathError{Op: "statfs", Path: path, Err: err})
+	}
+
+	if name, ok := filesystemMagics[uint32(st.Type)]; ok {
+		fs.Type = name
+	}
+	fs.Capabilities.MaxNameLength = int(st.Namelen)
+	fs.ReadOnly = st.Flags&unix.ST_RDONLY != 0
+	fs.NoExec = st.Flags&unix.ST_NOEXEC != 0
+	fs.NoSuid = st.Flags&unix.ST_NOSUID != 0
+
+	// mountinfo is more precise (it tells ext3 and ext4 apart,
+	// and has the FUSE subtype), but may not be readable
+	if mi, err := linox.MountFor(path); err == nil {
+		fs.Type = mi.FSType
+		fs.MountPoint = mi.MountPoint
+		fs.Source = mi.Source
+		fs.MountOptions = mi.Options
+	}
+	return nil
+}
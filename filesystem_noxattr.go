@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package ox
+
+func probeXattrs(path string) bool {
+	return false
+}
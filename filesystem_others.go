@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package ox
+
+func detectFilesystem(path string, fs *Filesystem) error {
+	// no portable way to find out
+	return nil
+}
@@ -0,0 +1,114 @@
+package ox_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FilesystemInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	must(err)
+	defer os.RemoveAll(dir)
+
+	ox.ClearFilesystemCache()
+	fs, err := ox.FilesystemInfo(dir)
+	must(err)
+	t.Logf("%s is on %+v", dir, fs)
+
+	assert.NotEmpty(fs.Type)
+	assert.True(fs.Capabilities.MaxNameLength > 0)
+
+	device, err := ox.DeviceID(dir)
+	must(err)
+	assert.Equal(device, fs.Device)
+
+	if !fs.ReadOnly {
+		assert.True(fs.Capabilities.Probed)
+		if runtime.GOOS == "linux" {
+			assert.True(fs.Capabilities.CaseSensitive)
+			assert.True(fs.Capabilities.Symlinks)
+		}
+	}
+
+	// probing cleans up after itself, wherever it happened
+	assertNoProbeLeft(t, probeLocation(t, dir, fs))
+
+	// paths that don't exist yet are fine, and cached
+	fs2, err := ox.FilesystemInfo(filepath.Join(dir, "does", "not", "exist.dat"))
+	must(err)
+	assert.Equal(fs, fs2)
+
+	if fs.Capabilities.Probed && fs.Capabilities.Fallocate {
+		assert.Equal(ox.PreallocateAuto, fs.PreallocateStrategy())
+	}
+}
+
+func Test_FilesystemInfoOtherDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	// tmpfs is usually a different device than the temp dir
+	if runtime.GOOS != "linux" {
+		t.Skip("needs /dev/shm")
+	}
+	dir, err := ioutil.TempDir("/dev/shm", "")
+	if err != nil {
+		t.Skipf("/dev/shm not usable: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	device, err := ox.DeviceID(dir)
+	must(err)
+	tempDevice, err := ox.DeviceID(os.TempDir())
+	must(err)
+	if device == tempDevice {
+		t.Skip("/dev/shm is on the same device as the temp dir")
+	}
+
+	ox.ClearFilesystemCache()
+	fs, err := ox.FilesystemInfo(dir)
+	must(err)
+	assert.Equal(device, fs.Device)
+	assert.True(fs.Capabilities.Probed)
+
+	// probing happened next to the path, and cleaned up
+	entries, err := ioutil.ReadDir(dir)
+	must(err)
+	assert.Empty(entries)
+}
+
+// probeLocation returns where FilesystemInfo probes capabilities for dir
+func probeLocation(t *testing.T, dir string, fs ox.Filesystem) string {
+	tempDevice, err := ox.DeviceID(os.TempDir())
+	must(err)
+	if tempDevice == fs.Device {
+		return os.TempDir()
+	}
+	return dir
+}
+
+func assertNoProbeLeft(t *testing.T, dir string) {
+	leftovers, err := filepath.Glob(filepath.Join(dir, ".ox-probe-*"))
+	must(err)
+	assert.Empty(t, leftovers, "probe files left in %s", dir)
+}
+
+func Test_FilesystemPreallocateStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	fs := ox.Filesystem{Type: "zfs"}
+	assert.Equal(ox.PreallocateAuto, fs.PreallocateStrategy())
+
+	fs.Capabilities.Probed = true
+	assert.Equal(ox.PreallocateZeroFill, fs.PreallocateStrategy())
+
+	fs.Capabilities.Fallocate = true
+	assert.Equal(ox.PreallocateAuto, fs.PreallocateStrategy())
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package ox
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+func detectFilesystem(path string, fs *Filesystem) error {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	volumePath := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumePathName(path16, &volumePath[0], uint32(len(volumePath)))
+	if err != nil {
+		return errors.WithStack(&os.PathError{Op: "GetVolumePathName", Path: path, Err: err})
+	}
+
+	var maxComponentLength, flags uint32
+	fsName := make([]uint16, windows.MAX_PATH+1)
+	err = windows.GetVolumeInformation(&volumePath[0], nil, 0, nil, &maxComponentLength, &flags, &fsName[0], uint32(len(fsName)))
+	if err != nil {
+		return errors.WithStack(&os.PathError{Op: "GetVolumeInformation", Path: path, Err: err})
+	}
+
+	fs.Type = strings.ToLower(windows.UTF16ToString(fsName))
+	fs.MountPoint = windows.UTF16ToString(volumePath)
+	fs.ReadOnly = flags&windows.FILE_READ_ONLY_VOLUME != 0
+	fs.Capabilities.MaxNameLength = int(maxComponentLength)
+	return nil
+}
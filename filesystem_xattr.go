@@ -0,0 +1,14 @@
+//go:build linux || darwin
+
+package ox
+
+import "golang.org/x/sys/unix"
+
+func probeXattrs(path string) bool {
+	err := unix.Setxattr(path, "user.ox-probe", []byte("1"), 0)
+	if err != nil {
+		return false
+	}
+	_ = unix.Removexattr(path, "user.ox-probe")
+	return true
+}
@@ -0,0 +1,172 @@
+package linox
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DistributionInfo describes a Linux distribution, as
+// found in os-release(5)
+type DistributionInfo struct {
+	// ID is a lowercase identifier, like "ubuntu" or "fedora"
+	ID string
+	// IDLike lists distributions this one is derived from, like ["debian"]
+	IDLike []string
+	// Name is a human-readable name, like "Ubuntu"
+	Name string
+	// VersionID is a machine-readable version, like "22.04" (may be empty on rolling releases)
+	VersionID string
+	// VariantID identifies editions, like "silverblue" or "workstation"
+	VariantID string
+	// PrettyName is the name to show users, like "Ubuntu 22.04.3 LTS"
+	PrettyName string
+
+	// OSTree is true if the system was booted from an OSTree deployment
+	OSTree bool
+	// Immutable is true for image-based systems with a read-only /usr,
+	// where installing system packages isn't an option (Fedora Silverblue,
+	// SteamOS, Endless OS, etc.)
+	Immutable bool
+
+	// Fields holds every field of os-release, unquoted
+	Fields map[string]string
+}
+
+// IsLike returns true if the distribution is id, or is derived from it
+func (di DistributionInfo) IsLike(id string) bool {
+	if di.ID == id {
+		return true
+	}
+	for _, like := range di.IDLike {
+		if like == id {
+			return true
+		}
+	}
+	return false
+}
+
+// osReleasePaths are tried in order, as recommended by os-release(5)
+var osReleasePaths = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// immutableIDs are distributions that are always image-based
+var immutableIDs = map[string]bool{
+	"steamos": true,
+	"endless": true,
+	"vanilla": true,
+}
+
+// immutableVariants are image-based editions of otherwise
+// regular distributions
+var immutableVariants = map[string]bool{
+	"silverblue": true,
+	"kinoite":    true,
+	"sericea":    true,
+	"onyx":       true,
+	"coreos":     true,
+	"iot":        true,
+}
+
+// Distribution returns information about the running Linux distribution
+func Distribution() (DistributionInfo, error) {
+	return DistributionAt("/")
+}
+
+// DistributionAt returns information about the Linux
+// distribution installed at root. Symlinks, like the usual
+// /etc/os-release -> /usr/lib/os-release, are resolved inside root.
+func DistributionAt(root string) (DistributionInfo, error) {
+	var f *os.File
+	var err error
+	for _, path := range osReleasePaths {
+		var resolved string
+		resolved, err = resolveInRoot(root, path)
+		if err != nil {
+			continue
+		}
+		f, err = os.Open(resolved)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return DistributionInfo{}, errors.Wrap(err, "while looking for os-release")
+	}
+	defer f.Close()
+
+	fields, err := ParseOSRelease(f)
+	if err != nil {
+		return DistributionInfo{}, errors.Wrapf(err, "while parsing %s", f.Name())
+	}
+
+	di := DistributionInfo{
+		ID:         fields["ID"],
+		IDLike:     strings.Fields(fields["ID_LIKE"]),
+		Name:       fields["NAME"],
+		VersionID:  fields["VERSION_ID"],
+		VariantID:  fields["VARIANT_ID"],
+		PrettyName: fields["PRETTY_NAME"],
+		Fields:     fields,
+	}
+	if di.ID == "" {
+		// as per os-release(5)
+		di.ID = "linux"
+	}
+
+	if _, err := resolveInRoot(root, "/run/ostree-booted"); err == nil {
+		di.OSTree = true
+	}
+	di.Immutable = di.OSTree || immutableIDs[di.ID] || immutableVariants[di.VariantID]
+
+	return di, nil
+}
+
+// ParseOSRelease parses the contents of an os-release file
+// into a map of fields, with values unquoted.
+func ParseOSRelease(r io.Reader) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = unquoteOSReleaseValue(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fields, nil
+}
+
+// unquoteOSReleaseValue follows shell quoting rules, as
+// required by os-release(5): values may be enclosed in single or
+// double quotes, and double-quoted values may contain escapes.
+func unquoteOSReleaseValue(value string) string {
+	if len(value) >= 2 {
+		switch {
+		case value[0] == '\'' && value[len(value)-1] == '\'':
+			return value[1 : len(value)-1]
+		case value[0] == '"' && value[len(value)-1] == '"':
+			value = value[1 : len(value)-1]
+			var sb strings.Builder
+			for i := 0; i < len(value); i++ {
+				if value[i] == '\\' && i+1 < len(value) && strings.IndexByte("\\\"$`", value[i+1]) >= 0 {
+					i++
+				}
+				sb.WriteByte(value[i])
+			}
+			return sb.String()
+		}
+	}
+	return value
+}
@@ -0,0 +1,140 @@
+package linox_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+const ubuntuOSRelease = `PRETTY_NAME="Ubuntu 22.04.3 LTS"
+NAME="Ubuntu"
+VERSION_ID="22.04"
+VERSION="22.04.3 LTS (Jammy Jellyfish)"
+VERSION_CODENAME=jammy
+ID=ubuntu
+ID_LIKE=debian
+HOME_URL="https://www.ubuntu.com/"
+UBUNTU_CODENAME=jammy
+`
+
+const silverblueOSRelease = `NAME="Fedora Linux"
+VERSION="39 (Silverblue)"
+ID=fedora
+VERSION_ID=39
+PRETTY_NAME="Fedora Linux 39 (Silverblue)"
+VARIANT="Silverblue"
+VARIANT_ID=silverblue
+`
+
+const steamOSRelease = `NAME="SteamOS"
+PRETTY_NAME="SteamOS"
+VERSION_CODENAME=holo
+ID=steamos
+ID_LIKE=arch
+VARIANT_ID=steamdeck
+`
+
+func TestDistributionAt(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"etc/os-release": ubuntuOSRelease,
+	})
+
+	di, err := linox.DistributionAt(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "ubuntu", di.ID)
+	assert.Equal(t, []string{"debian"}, di.IDLike)
+	assert.Equal(t, "Ubuntu", di.Name)
+	assert.Equal(t, "22.04", di.VersionID)
+	assert.Equal(t, "Ubuntu 22.04.3 LTS", di.PrettyName)
+	assert.Equal(t, "jammy", di.Fields["VERSION_CODENAME"])
+	assert.False(t, di.Immutable)
+	assert.False(t, di.OSTree)
+
+	assert.True(t, di.IsLike("ubuntu"))
+	assert.True(t, di.IsLike("debian"))
+	assert.False(t, di.IsLike("fedora"))
+}
+
+func TestDistributionAt_Fallback(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"usr/lib/os-release": steamOSRelease,
+	})
+
+	di, err := linox.DistributionAt(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "steamos", di.ID)
+	assert.True(t, di.IsLike("arch"))
+	assert.True(t, di.Immutable)
+}
+
+func TestDistributionAt_AbsoluteSymlink(t *testing.T) {
+	// the usual layout: /etc/os-release -> /usr/lib/os-release,
+	// which must be read from root, not from the host
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"usr/lib/os-release": silverblueOSRelease,
+	})
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/usr/lib/os-release", filepath.Join(root, "etc", "os-release")); err != nil {
+		t.Fatal(err)
+	}
+
+	di, err := linox.DistributionAt(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "fedora", di.ID)
+	assert.Equal(t, "silverblue", di.VariantID)
+}
+
+func TestDistributionAt_OSTree(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"etc/os-release":    silverblueOSRelease,
+		"run/ostree-booted": "",
+	})
+
+	di, err := linox.DistributionAt(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "fedora", di.ID)
+	assert.Equal(t, "silverblue", di.VariantID)
+	assert.True(t, di.OSTree)
+	assert.True(t, di.Immutable)
+}
+
+func TestDistributionAt_Missing(t *testing.T) {
+	_, err := linox.DistributionAt(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestParseOSRelease_Quoting(t *testing.T) {
+	fields, err := linox.ParseOSRelease(strings.NewReader(`# comment
+ID=void
+
+NAME='Single quoted'
+PRETTY_NAME="Escaped \"quotes\" and \$dollars"
+EMPTY=
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ID":          "void",
+		"NAME":        "Single quoted",
+		"PRETTY_NAME": `Escaped "quotes" and $dollars`,
+		"EMPTY":       "",
+	}, fields)
+}
+
+func TestDistribution(t *testing.T) {
+	di, err := linox.Distribution()
+	if err != nil {
+		t.Skipf("No os-release on this host: %v", err)
+	}
+	assert.NotEmpty(t, di.ID)
+	t.Logf("Host distribution: %s (%s)", di.PrettyName, di.ID)
+}
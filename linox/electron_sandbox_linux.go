@@ -0,0 +1,113 @@
+package linox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ElectronSandboxMode is how the Chromium sandbox of an
+// Electron app will be set up
+type ElectronSandboxMode string
+
+const (
+	// ElectronSandboxUserNamespace means Chromium can use its
+	// namespace sandbox, nothing special needs to be done.
+	ElectronSandboxUserNamespace ElectronSandboxMode = "userns"
+	// ElectronSandboxSUID means Chromium will fall back to
+	// the chrome-sandbox setuid helper.
+	ElectronSandboxSUID ElectronSandboxMode = "suid"
+	// ElectronSandboxDisabled means neither sandbox is usable, and
+	// the app must be launched with the sandbox disabled, or it
+	// will abort on startup.
+	ElectronSandboxDisabled ElectronSandboxMode = "disabled"
+)
+
+// ElectronSandboxHelper is the name of the setuid helper
+// shipped next to Electron executables
+const ElectronSandboxHelper = "chrome-sandbox"
+
+// ElectronSandboxDecision is returned by ElectronSandbox
+type ElectronSandboxDecision struct {
+	Mode ElectronSandboxMode
+	// Args should be appended to the app's command line
+	Args []string
+	// Env should be added to the app's environment, as KEY=value
+	Env []string
+	// Reason explains the decision, for logging
+	Reason string
+}
+
+// ElectronSandbox decides how an Electron (5.0+) app installed in
+// appDir should be launched, depending on whether unprivileged
+// user namespaces are available and whether its chrome-sandbox
+// helper is installed properly.
+// cf. https://github.com/electron/electron/issues/17972
+func ElectronSandbox(appDir string) ElectronSandboxDecision {
+	return DecideElectronSandbox(appDir, DiagnoseUserNamespaces().Supported)
+}
+
+// DecideElectronSandbox is like ElectronSandbox, but takes
+// whether user namespaces are available instead of checking it.
+func DecideElectronSandbox(appDir string, usernsSupported bool) ElectronSandboxDecision {
+	if usernsSupported {
+		return ElectronSandboxDecision{
+			Mode:   ElectronSandboxUserNamespace,
+			Reason: "unprivileged user namespaces are available",
+		}
+	}
+
+	helperPath := filepath.Join(appDir, ElectronSandboxHelper)
+	problem := checkSandboxHelper(helperPath)
+	if problem == "" {
+		return ElectronSandboxDecision{
+			Mode:   ElectronSandboxSUID,
+			Reason: fmt.Sprintf("unprivileged user namespaces are not available, using setuid helper %s", helperPath),
+		}
+	}
+
+	return ElectronSandboxDecision{
+		Mode: ElectronSandboxDisabled,
+		Args: []string{"--no-sandbox"},
+		Env:  []string{"ELECTRON_DISABLE_SANDBOX=1"},
+		Reason: fmt.Sprintf("unprivileged user namespaces are not available, and %s; disabling the sandbox (to fix, run `sudo chown root:root %s && sudo chmod 4755 %s`)",
+			problem, helperPath, helperPath),
+	}
+}
+
+// checkSandboxHelper returns what's wrong with the chrome-sandbox
+// helper at path, or an empty string if it's usable.
+func checkSandboxHelper(path string) string {
+	stats, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Sprintf("%s does not exist", path)
+		}
+		return fmt.Sprintf("%s could not be checked: %v", path, err)
+	}
+
+	if st, ok := stats.Sys().(*syscall.Stat_t); ok && st.Uid != 0 {
+		return fmt.Sprintf("%s is owned by uid %d instead of root", path, st.Uid)
+	}
+	mode := stats.Mode()
+	if mode&os.ModeSetuid == 0 || mode.Perm() != 0755 {
+		return fmt.Sprintf("%s has mode %04o instead of 4755", path, unixPerm(mode))
+	}
+	return ""
+}
+
+// unixPerm returns mode as the octal permissions chmod would take
+func unixPerm(mode os.FileMode) uint32 {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= 01000
+	}
+	return perm
+}
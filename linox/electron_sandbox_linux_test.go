@@ -0,0 +1,57 @@
+package linox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSandboxHelper(t *testing.T, mode os.FileMode) string {
+	appDir := t.TempDir()
+	helperPath := filepath.Join(appDir, linox.ElectronSandboxHelper)
+	assert.NoError(t, os.WriteFile(helperPath, []byte("ELF"), 0755))
+	assert.NoError(t, os.Chmod(helperPath, mode))
+	return appDir
+}
+
+func TestElectronSandbox_UserNamespace(t *testing.T) {
+	d := linox.DecideElectronSandbox(t.TempDir(), true)
+	assert.Equal(t, linox.ElectronSandboxUserNamespace, d.Mode)
+	assert.Empty(t, d.Args)
+	assert.Empty(t, d.Env)
+}
+
+func TestElectronSandbox_MissingHelper(t *testing.T) {
+	d := linox.DecideElectronSandbox(t.TempDir(), false)
+	assert.Equal(t, linox.ElectronSandboxDisabled, d.Mode)
+	assert.Equal(t, []string{"--no-sandbox"}, d.Args)
+	assert.Equal(t, []string{"ELECTRON_DISABLE_SANDBOX=1"}, d.Env)
+	assert.Contains(t, d.Reason, "does not exist")
+}
+
+func TestElectronSandbox_NotSetuid(t *testing.T) {
+	appDir := writeSandboxHelper(t, 0755)
+	d := linox.DecideElectronSandbox(appDir, false)
+	assert.Equal(t, linox.ElectronSandboxDisabled, d.Mode)
+	if os.Getuid() == 0 {
+		assert.Contains(t, d.Reason, "mode 0755 instead of 4755")
+	}
+	assert.Contains(t, d.Reason, "chmod 4755")
+}
+
+func TestElectronSandbox_SUID(t *testing.T) {
+	appDir := writeSandboxHelper(t, 0755|os.ModeSetuid)
+	d := linox.DecideElectronSandbox(appDir, false)
+	if os.Getuid() != 0 {
+		// can't make a root-owned helper
+		assert.Equal(t, linox.ElectronSandboxDisabled, d.Mode)
+		assert.Contains(t, d.Reason, "instead of root")
+		return
+	}
+	assert.Equal(t, linox.ElectronSandboxSUID, d.Mode)
+	assert.Empty(t, d.Args)
+	assert.Empty(t, d.Env)
+}
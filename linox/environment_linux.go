@@ -0,0 +1,291 @@
+package linox
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvironmentKind identifies a sandboxed or containerized
+// execution environment
+type EnvironmentKind string
+
+const (
+	EnvironmentFlatpak      EnvironmentKind = "flatpak"
+	EnvironmentSnap         EnvironmentKind = "snap"
+	EnvironmentAppImage     EnvironmentKind = "appimage"
+	EnvironmentSteamRuntime EnvironmentKind = "steam-runtime"
+	EnvironmentDocker       EnvironmentKind = "docker"
+	EnvironmentPodman       EnvironmentKind = "podman"
+	// EnvironmentContainer is any other container, see ContainerInfo.Engine
+	EnvironmentContainer EnvironmentKind = "container"
+)
+
+// FlatpakInfo is read from /.flatpak-info
+type FlatpakInfo struct {
+	// AppID is the application ID, like "io.itch.itch"
+	AppID string
+	// Runtime is the full runtime ref, like "runtime/org.freedesktop.Platform/x86_64/23.08"
+	Runtime        string
+	FlatpakVersion string
+	// Filesystems lists granted filesystem permissions, like "host" or "xdg-download:ro"
+	Filesystems []string
+	// Shared lists shared subsystems, like "network" or "ipc"
+	Shared []string
+	// Devices lists granted devices, like "dri" or "all"
+	Devices []string
+}
+
+// HasHostFilesystem returns true if the whole host
+// filesystem is visible from the sandbox
+func (fi FlatpakInfo) HasHostFilesystem() bool {
+	for _, fs := range fi.Filesystems {
+		if fs == "host" || fs == "host:rw" {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapInfo is read from SNAP* environment variables
+type SnapInfo struct {
+	Name     string
+	Revision string
+	Version  string
+	// Path is where the snap is mounted, read-only
+	Path string
+	// UserData is the per-revision writable directory for the user
+	UserData string
+}
+
+// AppImageInfo is read from APPIMAGE and APPDIR environment variables
+type AppImageInfo struct {
+	// Path is the path of the .AppImage file itself
+	Path string
+	// MountDir is where the AppImage is mounted
+	MountDir string
+}
+
+// SteamRuntimeInfo describes the Steam Linux Runtime
+type SteamRuntimeInfo struct {
+	// PressureVessel is true when running inside the container-based
+	// runtime (Steam Linux Runtime 1.0 and later), false for the older
+	// LD_LIBRARY_PATH-based runtime
+	PressureVessel bool
+	// Suite is the runtime's codename, like "scout", "soldier" or "sniper",
+	// if known
+	Suite string
+}
+
+// ContainerInfo describes an OCI, LXC or other container
+type ContainerInfo struct {
+	// Engine is "docker", "podman", "lxc", "kubernetes", or whatever
+	// the `container` environment variable says
+	Engine string
+	// Name and Image are only known for podman
+	Name  string
+	Image string
+}
+
+// EnvironmentInfo describes every sandboxed or containerized environment
+// we're running in. Several may apply at once, for example an AppImage
+// running in a Docker container. Fields are nil for environments that
+// weren't detected.
+type EnvironmentInfo struct {
+	Kinds        []EnvironmentKind
+	Flatpak      *FlatpakInfo
+	Snap         *SnapInfo
+	AppImage     *AppImageInfo
+	SteamRuntime *SteamRuntimeInfo
+	Container    *ContainerInfo
+}
+
+// Is returns true if kind was detected
+func (ei EnvironmentInfo) Is(kind EnvironmentKind) bool {
+	for _, k := range ei.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Sandboxed returns true if we're in an environment where host paths
+// may not be visible, and URLs must be opened through a portal or helper,
+// which is the case for Flatpak, Snap and the pressure-vessel Steam Runtime.
+func (ei EnvironmentInfo) Sandboxed() bool {
+	return ei.Flatpak != nil || ei.Snap != nil || (ei.SteamRuntime != nil && ei.SteamRuntime.PressureVessel)
+}
+
+// Environment detects the environment the current process runs in
+func Environment() EnvironmentInfo {
+	return DetectEnvironment("/", os.Getenv)
+}
+
+// DetectEnvironment detects the execution environment from the
+// filesystem at root and the environment variables getenv returns.
+func DetectEnvironment(root string, getenv func(key string) string) EnvironmentInfo {
+	var ei EnvironmentInfo
+
+	if f, err := os.Open(filepath.Join(root, "/.flatpak-info")); err == nil {
+		ei.Flatpak = parseFlatpakInfo(f)
+		f.Close()
+		if ei.Flatpak.AppID == "" {
+			ei.Flatpak.AppID = getenv("FLATPAK_ID")
+		}
+		ei.Kinds = append(ei.Kinds, EnvironmentFlatpak)
+	}
+
+	if getenv("SNAP") != "" && getenv("SNAP_NAME") != "" {
+		ei.Snap = &SnapInfo{
+			Name:     getenv("SNAP_NAME"),
+			Revision: getenv("SNAP_REVISION"),
+			Version:  getenv("SNAP_VERSION"),
+			Path:     getenv("SNAP"),
+			UserData: getenv("SNAP_USER_DATA"),
+		}
+		ei.Kinds = append(ei.Kinds, EnvironmentSnap)
+	}
+
+	if getenv("APPIMAGE") != "" {
+		ei.AppImage = &AppImageInfo{
+			Path:     getenv("APPIMAGE"),
+			MountDir: getenv("APPDIR"),
+		}
+		ei.Kinds = append(ei.Kinds, EnvironmentAppImage)
+	}
+
+	if srt := detectSteamRuntime(root, getenv); srt != nil {
+		ei.SteamRuntime = srt
+		ei.Kinds = append(ei.Kinds, EnvironmentSteamRuntime)
+	}
+
+	if ci := detectContainer(root, getenv); ci != nil {
+		ei.Container = ci
+		switch ci.Engine {
+		case "docker":
+			ei.Kinds = append(ei.Kinds, EnvironmentDocker)
+		case "podman":
+			ei.Kinds = append(ei.Kinds, EnvironmentPodman)
+		default:
+			ei.Kinds = append(ei.Kinds, EnvironmentContainer)
+		}
+	}
+
+	return ei
+}
+
+func parseFlatpakInfo(r io.Reader) *FlatpakInfo {
+	kf := parseKeyFile(r)
+	return &FlatpakInfo{
+		AppID:          kf["Application"]["name"],
+		Runtime:        kf["Application"]["runtime"],
+		FlatpakVersion: kf["Instance"]["flatpak-version"],
+		Filesystems:    splitKeyFileList(kf["Context"]["filesystems"]),
+		Shared:         splitKeyFileList(kf["Context"]["shared"]),
+		Devices:        splitKeyFileList(kf["Context"]["devices"]),
+	}
+}
+
+func detectSteamRuntime(root string, getenv func(key string) string) *SteamRuntimeInfo {
+	if _, err := os.Stat(filepath.Join(root, "/run/pressure-vessel")); err == nil {
+		srt := &SteamRuntimeInfo{PressureVessel: true}
+		if di, err := DistributionAt(root); err == nil && di.ID == "steamrt" {
+			srt.Suite = di.Fields["VERSION_CODENAME"]
+		}
+		return srt
+	}
+
+	// the older runtime is just a set of libraries, pointed to by STEAM_RUNTIME
+	if value := getenv("STEAM_RUNTIME"); value != "" && value != "0" {
+		return &SteamRuntimeInfo{Suite: "scout"}
+	}
+	return nil
+}
+
+// cgroupEngines maps substrings of /proc/self/cgroup entries
+// to container engines
+var cgroupEngines = []struct {
+	needle string
+	engine string
+}{
+	{"libpod", "podman"},
+	{"docker", "docker"},
+	{"kubepods", "kubernetes"},
+	{"/lxc/", "lxc"},
+	{"lxc.payload", "lxc"},
+}
+
+func detectContainer(root string, getenv func(key string) string) *ContainerInfo {
+	if f, err := os.Open(filepath.Join(root, "/run/.containerenv")); err == nil {
+		defer f.Close()
+		kf := parseKeyFile(f)
+		return &ContainerInfo{
+			Engine: "podman",
+			Name:   kf[""]["name"],
+			Image:  kf[""]["image"],
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "/.dockerenv")); err == nil {
+		return &ContainerInfo{Engine: "docker"}
+	}
+
+	if cgroup, err := os.ReadFile(filepath.Join(root, "/proc/self/cgroup")); err == nil {
+		for _, ce := range cgroupEngines {
+			if strings.Contains(string(cgroup), ce.needle) {
+				return &ContainerInfo{Engine: ce.engine}
+			}
+		}
+	}
+
+	// set by systemd-nspawn, LXC and others, cf. https://systemd.io/CONTAINER_INTERFACE/
+	// flatpak sets it too, but we handle that separately.
+	if engine := getenv("container"); engine != "" && engine != "flatpak" {
+		return &ContainerInfo{Engine: engine}
+	}
+
+	return nil
+}
+
+// parseKeyFile parses desktop-entry style "key files", as used by
+// flatpak-info, and the simpler key=value format of .containerenv.
+// Keys outside of any section end up in the "" section.
+func parseKeyFile(r io.Reader) map[string]map[string]string {
+	kf := map[string]map[string]string{"": {}}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			if kf[section] == nil {
+				kf[section] = make(map[string]string)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		kf[section][strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return kf
+}
+
+func splitKeyFileList(value string) []string {
+	var res []string
+	for _, item := range strings.Split(value, ";") {
+		if item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
@@ -0,0 +1,147 @@
+package linox_test
+
+import (
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+const flatpakInfo = `[Application]
+name=io.itch.itch
+runtime=runtime/org.freedesktop.Platform/x86_64/23.08
+
+[Instance]
+instance-id=1234567890
+branch=stable
+arch=x86_64
+flatpak-version=1.14.4
+
+[Context]
+shared=network;ipc;
+sockets=x11;wayland;pulseaudio;
+devices=dri;
+filesystems=xdg-download;~/Games:create;
+`
+
+func TestDetectEnvironment_None(t *testing.T) {
+	ei := linox.DetectEnvironment(t.TempDir(), fakeEnv(nil))
+	assert.Empty(t, ei.Kinds)
+	assert.False(t, ei.Sandboxed())
+}
+
+func TestDetectEnvironment_Flatpak(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		".flatpak-info": flatpakInfo,
+	})
+
+	ei := linox.DetectEnvironment(root, fakeEnv(map[string]string{"container": "flatpak"}))
+	assert.Equal(t, []linox.EnvironmentKind{linox.EnvironmentFlatpak}, ei.Kinds)
+	assert.True(t, ei.Sandboxed())
+	if assert.NotNil(t, ei.Flatpak) {
+		assert.Equal(t, "io.itch.itch", ei.Flatpak.AppID)
+		assert.Equal(t, "runtime/org.freedesktop.Platform/x86_64/23.08", ei.Flatpak.Runtime)
+		assert.Equal(t, "1.14.4", ei.Flatpak.FlatpakVersion)
+		assert.Equal(t, []string{"xdg-download", "~/Games:create"}, ei.Flatpak.Filesystems)
+		assert.Equal(t, []string{"network", "ipc"}, ei.Flatpak.Shared)
+		assert.Equal(t, []string{"dri"}, ei.Flatpak.Devices)
+		assert.False(t, ei.Flatpak.HasHostFilesystem())
+	}
+	assert.Nil(t, ei.Container, "container=flatpak should not count as a container")
+}
+
+func TestDetectEnvironment_Snap(t *testing.T) {
+	ei := linox.DetectEnvironment(t.TempDir(), fakeEnv(map[string]string{
+		"SNAP":           "/snap/itch/42",
+		"SNAP_NAME":      "itch",
+		"SNAP_REVISION":  "42",
+		"SNAP_VERSION":   "26.1.3",
+		"SNAP_USER_DATA": "/home/amos/snap/itch/42",
+	}))
+	assert.True(t, ei.Is(linox.EnvironmentSnap))
+	assert.True(t, ei.Sandboxed())
+	assert.Equal(t, &linox.SnapInfo{
+		Name:     "itch",
+		Revision: "42",
+		Version:  "26.1.3",
+		Path:     "/snap/itch/42",
+		UserData: "/home/amos/snap/itch/42",
+	}, ei.Snap)
+}
+
+func TestDetectEnvironment_AppImageInDocker(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		".dockerenv": "",
+	})
+
+	ei := linox.DetectEnvironment(root, fakeEnv(map[string]string{
+		"APPIMAGE": "/home/user/itch.AppImage",
+		"APPDIR":   "/tmp/.mount_itchXYZ",
+	}))
+	assert.Equal(t, []linox.EnvironmentKind{linox.EnvironmentAppImage, linox.EnvironmentDocker}, ei.Kinds)
+	assert.False(t, ei.Sandboxed())
+	assert.Equal(t, &linox.AppImageInfo{Path: "/home/user/itch.AppImage", MountDir: "/tmp/.mount_itchXYZ"}, ei.AppImage)
+	assert.Equal(t, "docker", ei.Container.Engine)
+}
+
+func TestDetectEnvironment_Podman(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"run/.containerenv": "engine=\"podman-4.9.3\"\nname=\"builder\"\nimage=\"docker.io/library/debian:bookworm\"\nrootless=1\n",
+	})
+
+	ei := linox.DetectEnvironment(root, fakeEnv(nil))
+	assert.True(t, ei.Is(linox.EnvironmentPodman))
+	assert.Equal(t, &linox.ContainerInfo{
+		Engine: "podman",
+		Name:   "builder",
+		Image:  "docker.io/library/debian:bookworm",
+	}, ei.Container)
+}
+
+func TestDetectEnvironment_Cgroup(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/self/cgroup": "12:cpu,cpuacct:/kubepods/besteffort/pod1234/abcdef\n0::/\n",
+	})
+
+	ei := linox.DetectEnvironment(root, fakeEnv(nil))
+	assert.True(t, ei.Is(linox.EnvironmentContainer))
+	assert.Equal(t, "kubernetes", ei.Container.Engine)
+}
+
+func TestDetectEnvironment_SteamRuntime(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"run/pressure-vessel/ldlp": "",
+		"usr/lib/os-release":       "NAME=\"Steam Runtime\"\nID=steamrt\nVERSION_CODENAME=sniper\n",
+	})
+
+	ei := linox.DetectEnvironment(root, fakeEnv(nil))
+	assert.True(t, ei.Is(linox.EnvironmentSteamRuntime))
+	assert.True(t, ei.Sandboxed())
+	assert.Equal(t, &linox.SteamRuntimeInfo{PressureVessel: true, Suite: "sniper"}, ei.SteamRuntime)
+
+	// LD_LIBRARY_PATH runtime
+	ei = linox.DetectEnvironment(t.TempDir(), fakeEnv(map[string]string{
+		"STEAM_RUNTIME": "/home/user/.steam/ubuntu12_32/steam-runtime",
+	}))
+	assert.Equal(t, &linox.SteamRuntimeInfo{PressureVessel: false, Suite: "scout"}, ei.SteamRuntime)
+	assert.False(t, ei.Sandboxed())
+
+	ei = linox.DetectEnvironment(t.TempDir(), fakeEnv(map[string]string{"STEAM_RUNTIME": "0"}))
+	assert.Nil(t, ei.SteamRuntime)
+}
+
+func TestEnvironment(t *testing.T) {
+	ei := linox.Environment()
+	t.Logf("Host environment: %v", ei.Kinds)
+}
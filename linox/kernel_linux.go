@@ -0,0 +1,226 @@
+package linox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// KernelRelease is a Linux kernel version, as found
+// in `uname -r`, e.g. "5.15.0-91-generic"
+type KernelRelease struct {
+	Major int
+	Minor int
+	Patch int
+	// Suffix is whatever comes after the version number,
+	// e.g. "-91-generic" or "-microsoft-standard-WSL2"
+	Suffix string
+	// Raw is the release string, as parsed
+	Raw string
+}
+
+var kernelReleaseRe = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?(.*)$`)
+
+// ParseKernelRelease parses a kernel release string, like
+// "6.5.0-14-generic", "5.15.133.1-microsoft-standard-WSL2" or "4.19.0+"
+func ParseKernelRelease(release string) (KernelRelease, error) {
+	release = strings.TrimSpace(release)
+	m := kernelReleaseRe.FindStringSubmatch(release)
+	if m == nil {
+		return KernelRelease{}, errors.Errorf("invalid kernel release %q", release)
+	}
+
+	kr := KernelRelease{Suffix: m[4], Raw: release}
+	kr.Major, _ = strconv.Atoi(m[1])
+	kr.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		kr.Patch, _ = strconv.Atoi(m[3])
+	}
+	return kr, nil
+}
+
+// KernelVersion returns the release of the running Linux kernel
+func KernelVersion() (KernelRelease, error) {
+	var uts unix.Utsname
+	err := unix.Uname(&uts)
+	if err != nil {
+		return KernelRelease{}, errors.WithStack(err)
+	}
+	return ParseKernelRelease(unix.ByteSliceToString(uts.Release[:]))
+}
+
+func (kr KernelRelease) String() string {
+	if kr.Raw != "" {
+		return kr.Raw
+	}
+	return fmt.Sprintf("%d.%d.%d%s", kr.Major, kr.Minor, kr.Patch, kr.Suffix)
+}
+
+// Compare compares version numbers, ignoring suffixes,
+// returning -1, 0 or 1.
+func (kr KernelRelease) Compare(other KernelRelease) int {
+	a := []int{kr.Major, kr.Minor, kr.Patch}
+	b := []int{other.Major, other.Minor, other.Patch}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast returns true if the kernel version is greater
+// than or equal to version, e.g. "5.13"
+func (kr KernelRelease) AtLeast(version string) bool {
+	other, err := ParseKernelRelease(version)
+	if err != nil {
+		return false
+	}
+	return kr.Compare(other) >= 0
+}
+
+// IsWSL returns true for Windows Subsystem for Linux kernels
+func (kr KernelRelease) IsWSL() bool {
+	return strings.Contains(strings.ToLower(kr.Suffix), "microsoft")
+}
+
+// KernelFeature is a kernel facility that can be probed for
+type KernelFeature string
+
+const (
+	// KernelFeaturePidfd is pidfd_open(2)
+	KernelFeaturePidfd KernelFeature = "pidfd"
+	// KernelFeatureClone3 is clone3(2)
+	KernelFeatureClone3 KernelFeature = "clone3"
+	// KernelFeatureLandlock is the Landlock security module
+	KernelFeatureLandlock KernelFeature = "landlock"
+	// KernelFeatureCgroup2 is the cgroup v2 filesystem
+	KernelFeatureCgroup2 KernelFeature = "cgroup2"
+)
+
+// KernelFeatureSupport is returned by ProbeKernelFeature
+type KernelFeatureSupport struct {
+	Feature   KernelFeature
+	Supported bool
+	// Probed is true if the answer comes from trying the feature,
+	// false if it was guessed from the kernel version
+	Probed bool
+	// MinVersion is the first kernel version with the feature
+	MinVersion string
+}
+
+// kernelFeatureProbe returns whether a feature is supported, and
+// whether that answer is definitive. Probes are inconclusive when a
+// seccomp filter or security module denies them.
+type kernelFeatureProbe func() (supported bool, ok bool)
+
+var kernelFeatures = map[KernelFeature]struct {
+	minVersion string
+	probe      kernelFeatureProbe
+}{
+	KernelFeaturePidfd:    {"5.3", probePidfd},
+	KernelFeatureClone3:   {"5.3", probeClone3},
+	KernelFeatureLandlock: {"5.13", probeLandlock},
+	KernelFeatureCgroup2:  {"4.5", probeCgroup2},
+}
+
+// ProbeKernelFeature tries to use feature, falling back
+// to checking the kernel version if that's inconclusive.
+func ProbeKernelFeature(feature KernelFeature) (KernelFeatureSupport, error) {
+	kf, ok := kernelFeatures[feature]
+	if !ok {
+		return KernelFeatureSupport{}, errors.Errorf("unknown kernel feature %q", feature)
+	}
+
+	res := KernelFeatureSupport{Feature: feature, MinVersion: kf.minVersion}
+	if supported, ok := kf.probe(); ok {
+		res.Supported = supported
+		res.Probed = true
+		return res, nil
+	}
+
+	kr, err := KernelVersion()
+	if err != nil {
+		return res, err
+	}
+	res.Supported = kr.AtLeast(kf.minVersion)
+	return res, nil
+}
+
+// HasKernelFeature returns true if the running kernel supports feature
+func HasKernelFeature(feature KernelFeature) bool {
+	res, err := ProbeKernelFeature(feature)
+	return err == nil && res.Supported
+}
+
+func probePidfd() (bool, bool) {
+	fd, err := unix.PidfdOpen(os.Getpid(), 0)
+	switch err {
+	case nil:
+		unix.Close(fd)
+		return true, true
+	case unix.ENOSYS:
+		return false, true
+	}
+	return false, false
+}
+
+func probeClone3() (bool, bool) {
+	// a zero-sized clone_args is rejected before anything gets cloned
+	_, _, errno := unix.Syscall(unix.SYS_CLONE3, 0, 0, 0)
+	switch errno {
+	case unix.EINVAL:
+		return true, true
+	case unix.ENOSYS:
+		return false, true
+	}
+	return false, false
+}
+
+func probeLandlock() (bool, bool) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	switch errno {
+	case 0:
+		return abi >= 1, true
+	case unix.ENOSYS, unix.EOPNOTSUPP:
+		// EOPNOTSUPP means it was built, but disabled at boot
+		return false, true
+	}
+	return false, false
+}
+
+func probeCgroup2() (bool, bool) {
+	var st unix.Statfs_t
+	if err := unix.Statfs("/sys/fs/cgroup", &st); err == nil && st.Type == unix.CGROUP2_SUPER_MAGIC {
+		return true, true
+	}
+
+	contents, err := os.ReadFile("/proc/filesystems")
+	if err != nil {
+		return false, false
+	}
+	return hasFilesystem(contents, "cgroup2"), true
+}
+
+// hasFilesystem returns true if the contents of
+// /proc/filesystems list fstype
+func hasFilesystem(contents []byte, fstype string) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == fstype {
+			return true
+		}
+	}
+	return false
+}
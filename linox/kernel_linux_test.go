@@ -0,0 +1,81 @@
+package linox_test
+
+import (
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKernelRelease(t *testing.T) {
+	cases := []struct {
+		release string
+		major   int
+		minor   int
+		patch   int
+		suffix  string
+	}{
+		{"6.5.0-14-generic", 6, 5, 0, "-14-generic"},
+		{"5.15.133.1-microsoft-standard-WSL2", 5, 15, 133, ".1-microsoft-standard-WSL2"},
+		{"4.19.0+", 4, 19, 0, "+"},
+		{"6.7", 6, 7, 0, ""},
+		{"5.10.0-26-amd64\n", 5, 10, 0, "-26-amd64"},
+		{"6.6.7-arch1-1", 6, 6, 7, "-arch1-1"},
+	}
+	for _, c := range cases {
+		kr, err := linox.ParseKernelRelease(c.release)
+		assert.NoError(t, err, c.release)
+		assert.Equal(t, c.major, kr.Major, c.release)
+		assert.Equal(t, c.minor, kr.Minor, c.release)
+		assert.Equal(t, c.patch, kr.Patch, c.release)
+		assert.Equal(t, c.suffix, kr.Suffix, c.release)
+	}
+
+	_, err := linox.ParseKernelRelease("generic")
+	assert.Error(t, err)
+}
+
+func TestKernelReleaseCompare(t *testing.T) {
+	kr, err := linox.ParseKernelRelease("5.15.133.1-microsoft-standard-WSL2")
+	assert.NoError(t, err)
+	assert.True(t, kr.IsWSL())
+	assert.True(t, kr.AtLeast("5.13"))
+	assert.True(t, kr.AtLeast("5.15.133"))
+	assert.False(t, kr.AtLeast("5.15.134"))
+	assert.False(t, kr.AtLeast("6.0"))
+	assert.False(t, kr.AtLeast("garbage"))
+
+	other, err := linox.ParseKernelRelease("5.3.0-generic")
+	assert.NoError(t, err)
+	assert.False(t, other.IsWSL())
+	assert.Equal(t, 1, kr.Compare(other))
+	assert.Equal(t, -1, other.Compare(kr))
+	assert.Equal(t, 0, other.Compare(linox.KernelRelease{Major: 5, Minor: 3}))
+}
+
+func TestKernelVersion(t *testing.T) {
+	kr, err := linox.KernelVersion()
+	assert.NoError(t, err)
+	assert.True(t, kr.Major >= 2)
+	assert.NotEmpty(t, kr.String())
+}
+
+func TestProbeKernelFeature(t *testing.T) {
+	// probe results aren't checked against the kernel version:
+	// distributions backport features (RHEL 8's 4.18 has pidfd)
+	for _, feature := range []linox.KernelFeature{
+		linox.KernelFeaturePidfd,
+		linox.KernelFeatureClone3,
+		linox.KernelFeatureLandlock,
+		linox.KernelFeatureCgroup2,
+	} {
+		res, err := linox.ProbeKernelFeature(feature)
+		assert.NoError(t, err, feature)
+		assert.Equal(t, feature, res.Feature)
+		assert.NotEmpty(t, res.MinVersion)
+		assert.Equal(t, res.Supported, linox.HasKernelFeature(feature))
+	}
+
+	_, err := linox.ProbeKernelFeature("time-travel")
+	assert.Error(t, err)
+}
@@ -0,0 +1,338 @@
+package linox
+
+import (
+	"bufio"
+	"debug/elf"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LibcFlavor is the C library implementation a Linux system uses
+type LibcFlavor string
+
+const (
+	LibcGlibc   LibcFlavor = "glibc"
+	LibcMusl    LibcFlavor = "musl"
+	LibcUnknown LibcFlavor = "unknown"
+)
+
+// LibcInfo describes the C library of a Linux system
+type LibcInfo struct {
+	Flavor LibcFlavor
+	// Version is only determined for glibc, e.g. "2.35"
+	Version string
+	// Interpreter is the dynamic loader executables use,
+	// e.g. "/lib64/ld-linux-x86-64.so.2"
+	Interpreter string
+	// LibcPath is the C library Version was read from
+	LibcPath string
+}
+
+// AtLeast returns true if the C library is glibc, and its
+// version is greater than or equal to version, e.g. "2.34"
+func (li LibcInfo) AtLeast(version string) bool {
+	if li.Flavor != LibcGlibc || li.Version == "" {
+		return false
+	}
+	return compareVersions(li.Version, version) >= 0
+}
+
+// Libc detects the C library of the running system
+func Libc() (LibcInfo, error) {
+	return DetectLibc("/")
+}
+
+// libcProbes are executables whose ELF interpreter
+// is that of the system
+var libcProbes = []string{"/bin/sh", "/usr/bin/env"}
+
+// loaderGlobs are where dynamic loaders live, if we
+// couldn't read the interpreter of libcProbes
+var loaderGlobs = []string{
+	"/lib/ld-musl-*.so.1",
+	"/lib64/ld-linux*.so.*",
+	"/lib/ld-linux*.so.*",
+	"/lib/*/ld-linux*.so.*",
+	"/usr/lib/*/ld-linux*.so.*",
+}
+
+// libcDirs are searched for libc.so.6, in addition to the
+// directory the interpreter lives in
+var libcDirs = []string{
+	"/lib64",
+	"/lib",
+	"/usr/lib64",
+	"/usr/lib",
+	"/lib/*-linux-*",
+	"/usr/lib/*-linux-*",
+}
+
+// DetectLibc detects the C library of the Linux system at root,
+// without running anything: it reads the ELF interpreter of system
+// executables, and the contents of libc.so.6 for the glibc version.
+func DetectLibc(root string) (LibcInfo, error) {
+	_, err := os.Stat(root)
+	if err != nil {
+		return LibcInfo{}, errors.WithStack(err)
+	}
+
+	li := LibcInfo{Flavor: LibcUnknown}
+
+	for _, probe := range libcProbes {
+		probePath, err := resolveInRoot(root, probe)
+		if err != nil {
+			continue
+		}
+		interp, err := readInterpreter(probePath)
+		if err == nil && interp != "" {
+			li.Interpreter = interp
+			break
+		}
+	}
+
+	if li.Interpreter == "" {
+		for _, pattern := range loaderGlobs {
+			if matches := globInRoot(root, pattern); len(matches) > 0 {
+				li.Interpreter = matches[0]
+				break
+			}
+		}
+	}
+
+	base := filepath.Base(li.Interpreter)
+	switch {
+	case strings.HasPrefix(base, "ld-musl"):
+		li.Flavor = LibcMusl
+	case strings.HasPrefix(base, "ld-linux"), strings.HasPrefix(base, "ld64.so"), base == "ld.so.1":
+		li.Flavor = LibcGlibc
+	}
+
+	if li.Flavor == LibcGlibc {
+		li.LibcPath, li.Version = findGlibcVersion(root, filepath.Dir(li.Interpreter))
+	}
+
+	return li, nil
+}
+
+// rootedPath returns path as seen from inside root
+func rootedPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// maxSymlinks is how many symlinks resolveInRoot follows
+// before giving up, like the kernel's ELOOP limit
+const maxSymlinks = 40
+
+// resolveInRoot resolves symlinks in path as if root was "/", and returns
+// where it ends up on the host. Absolute symlinks, and ".." components,
+// never lead outside of root, so a system image can't make us read
+// the host's files instead of its own.
+func resolveInRoot(root string, path string) (string, error) {
+	resolved := "/"
+	rest := strings.Split(filepath.ToSlash(path), "/")
+	links := 0
+
+	for len(rest) > 0 {
+		name := rest[0]
+		rest = rest[1:]
+
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, name)
+		stats, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if stats.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", errors.Errorf("too many levels of symbolic links in %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(filepath.ToSlash(target), "/"), rest...)
+	}
+
+	return filepath.Join(root, resolved), nil
+}
+
+// globInRoot returns the paths matching pattern inside of root, as
+// seen from inside root. The directory part before the first wildcard
+// is resolved with resolveInRoot, the last component of matches isn't.
+func globInRoot(root string, pattern string) []string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	rel, err := filepath.Rel(dir, pattern)
+	if err != nil {
+		return nil
+	}
+
+	resolvedDir, err := resolveInRoot(root, dir)
+	if err != nil {
+		return nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(resolvedDir, rel))
+	var rooted []string
+	for _, match := range matches {
+		rooted = append(rooted, rootedPath(root, match))
+	}
+	return rooted
+}
+
+// readInterpreter returns the PT_INTERP of an ELF executable
+func readInterpreter(path string) (string, error) {
+	ef, err := elf.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer ef.Close()
+
+	for _, prog := range ef.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		buf := make([]byte, prog.Filesz)
+		_, err := prog.ReadAt(buf, 0)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\x00"), nil
+	}
+	return "", nil
+}
+
+var (
+	// e.g. "GNU C Library (Ubuntu GLIBC 2.35-0ubuntu3.1) stable release version 2.35."
+	glibcBannerRe = regexp.MustCompile(`GNU C Library[^\n]*? release version (\d+\.\d+(?:\.\d+)?)`)
+	// e.g. "libc-2.31.so", which libc.so.6 links to on older distros
+	glibcFileRe = regexp.MustCompile(`^libc-(\d+\.\d+(?:\.\d+)?)\.so$`)
+	// version definitions, e.g. "GLIBC_2.34"
+	glibcSymbolVersionRe = regexp.MustCompile(`GLIBC_(2\.\d+(?:\.\d+)?)\x00`)
+)
+
+func findGlibcVersion(root string, interpDir string) (string, string) {
+	dirs := []string{interpDir}
+	dirs = append(dirs, libcDirs...)
+
+	for _, dir := range dirs {
+		for _, libcPath := range globInRoot(root, filepath.Join(dir, "libc.so.6")) {
+			if version := readGlibcVersion(root, libcPath); version != "" {
+				return libcPath, version
+			}
+		}
+	}
+	return "", ""
+}
+
+// readGlibcVersion finds the version of the glibc at libcPath,
+// as seen from inside root
+func readGlibcVersion(root string, libcPath string) string {
+	if dir, err := resolveInRoot(root, filepath.Dir(libcPath)); err == nil {
+		if target, err := os.Readlink(filepath.Join(dir, filepath.Base(libcPath))); err == nil {
+			if m := glibcFileRe.FindStringSubmatch(filepath.Base(target)); m != nil {
+				return m[1]
+			}
+		}
+	}
+
+	resolved, err := resolveInRoot(root, libcPath)
+	if err != nil {
+		return ""
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	return scanGlibcVersion(f)
+}
+
+const (
+	// libcScanChunkSize is how much of libc is searched at once
+	libcScanChunkSize = 64 * 1024
+	// libcScanOverlap is how much of the previous chunk is searched
+	// again, so that matches across chunk boundaries aren't missed
+	libcScanOverlap = 256
+)
+
+// scanGlibcVersion looks for the glibc banner in the contents of
+// libc, a chunk at a time, since it's a couple megabytes. Without a
+// banner, it picks the highest symbol version libc defines.
+func scanGlibcVersion(r io.Reader) string {
+	br := bufio.NewReaderSize(r, libcScanChunkSize)
+	window := make([]byte, libcScanOverlap+libcScanChunkSize)
+	kept := 0
+
+	var best string
+	for {
+		n, err := io.ReadFull(br, window[kept:])
+		data := window[:kept+n]
+
+		if m := glibcBannerRe.FindSubmatch(data); m != nil {
+			return string(m[1])
+		}
+		for _, m := range glibcSymbolVersionRe.FindAllSubmatch(data, -1) {
+			if best == "" || compareVersions(string(m[1]), best) > 0 {
+				best = string(m[1])
+			}
+		}
+
+		if err != nil {
+			// EOF, or a read error: go with what we have
+			return best
+		}
+		kept = copy(window, data[len(data)-libcScanOverlap:])
+	}
+}
+
+// compareVersions compares dotted version numbers like "2.35" and "2.4",
+// returning -1, 0 or 1.
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var an, bn int
+		if i < len(as) {
+			an, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bn, _ = strconv.Atoi(bs[i])
+		}
+		if an < bn {
+			return -1
+		}
+		if an > bn {
+			return 1
+		}
+	}
+	return 0
+}
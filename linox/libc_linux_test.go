@@ -0,0 +1,140 @@
+package linox_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFixture creates files under root, creating parent directories
+func writeFixture(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetectLibc_Glibc(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"lib64/ld-linux-x86-64.so.2":     "",
+		"lib/x86_64-linux-gnu/libc.so.6": "\x7fELF...GNU C Library (Ubuntu GLIBC 2.35-0ubuntu3.1) stable release version 2.35.\nCopyright...",
+	})
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, linox.LibcGlibc, li.Flavor)
+	assert.Equal(t, "2.35", li.Version)
+	assert.Equal(t, "/lib64/ld-linux-x86-64.so.2", li.Interpreter)
+	assert.Equal(t, "/lib/x86_64-linux-gnu/libc.so.6", li.LibcPath)
+	assert.True(t, li.AtLeast("2.34"))
+	assert.True(t, li.AtLeast("2.35"))
+	assert.False(t, li.AtLeast("2.36"))
+	assert.True(t, li.AtLeast("2.4"), "versions are compared numerically")
+}
+
+func TestDetectLibc_GlibcSymbolVersions(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"lib/ld-linux-armhf.so.3":           "",
+		"lib/arm-linux-gnueabihf/libc.so.6": "GLIBC_2.4\x00GLIBC_2.28\x00GLIBC_2.9\x00GLIBC_PRIVATE\x00",
+	})
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, linox.LibcGlibc, li.Flavor)
+	assert.Equal(t, "2.28", li.Version)
+}
+
+func TestDetectLibc_GlibcFilename(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"lib64/ld-linux-x86-64.so.2": "",
+		"lib64/libc-2.17.so":         "",
+	})
+	if err := os.Symlink("libc-2.17.so", filepath.Join(root, "lib64", "libc.so.6")); err != nil {
+		t.Fatal(err)
+	}
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.17", li.Version)
+	assert.False(t, li.AtLeast("2.34"))
+}
+
+func TestDetectLibc_AbsoluteSymlinks(t *testing.T) {
+	// merged-usr images use absolute symlinks, which
+	// must resolve inside root, not on the host
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"usr/lib64/ld-linux-x86-64.so.2": "",
+		"usr/lib64/libc.so.6":            "GNU C Library (GNU libc) stable release version 2.99.",
+	})
+	for name, target := range map[string]string{
+		"lib64":   "/usr/lib64",
+		"bin":     "/../../usr/bin",
+		"usr/bin": "/does-not-exist",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, linox.LibcGlibc, li.Flavor)
+	assert.Equal(t, "/usr/lib64/ld-linux-x86-64.so.2", li.Interpreter)
+	assert.Equal(t, "2.99", li.Version)
+}
+
+func TestDetectLibc_LargeLibc(t *testing.T) {
+	// the banner straddles two chunks of the scan
+	padding := strings.Repeat("\x00", 64*1024-20)
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"lib64/ld-linux-x86-64.so.2": "",
+		"lib64/libc.so.6":            padding + "GLIBC_2.2\x00GNU C Library (GNU libc) stable release version 2.38.\n" + padding,
+	})
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.38", li.Version)
+}
+
+func TestDetectLibc_Musl(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"lib/ld-musl-x86_64.so.1": "",
+	})
+
+	li, err := linox.DetectLibc(root)
+	assert.NoError(t, err)
+	assert.Equal(t, linox.LibcMusl, li.Flavor)
+	assert.Equal(t, "/lib/ld-musl-x86_64.so.1", li.Interpreter)
+	assert.Equal(t, "", li.Version)
+	assert.False(t, li.AtLeast("2.17"))
+}
+
+func TestDetectLibc_Unknown(t *testing.T) {
+	li, err := linox.DetectLibc(t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, linox.LibcUnknown, li.Flavor)
+
+	_, err = linox.DetectLibc(filepath.Join(t.TempDir(), "does-not-exist"))
+	assert.Error(t, err)
+}
+
+func TestLibc(t *testing.T) {
+	li, err := linox.Libc()
+	assert.NoError(t, err)
+	t.Logf("Host libc: %+v", li)
+}
@@ -0,0 +1,107 @@
+package linox
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// MountCheck describes restrictions of the mount a path is on,
+// that would prevent installed software from running
+type MountCheck struct {
+	// Path is the path that was checked, absolute and without symlinks
+	Path       string
+	MountPoint string
+	// Source is usually the device, like "/dev/sdb1"
+	Source string
+	FSType string
+
+	NoExec   bool
+	ReadOnly bool
+	NoSuid   bool
+
+	// Checks has one entry per restriction, each of them a problem
+	Checks []Check
+}
+
+// OK returns true if nothing would prevent installing
+// and running software on the mount
+func (mc MountCheck) OK() bool {
+	for _, c := range mc.Checks {
+		if c.Status == CheckBlocking {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckMount finds the mount path is on, and checks whether it's
+// mounted read-only, noexec or nosuid. path doesn't need to exist yet.
+func CheckMount(path string) (MountCheck, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return MountCheck{}, err
+	}
+
+	mounts, err := Mounts()
+	if err != nil {
+		return MountCheck{}, err
+	}
+
+	return CheckMountIn(mounts, resolved)
+}
+
+// CheckMountIn is like CheckMount, but looks for path in mounts.
+// path must be absolute and free of symlinks.
+func CheckMountIn(mounts []MountInfo, path string) (MountCheck, error) {
+	mi, ok := FindMount(mounts, path)
+	if !ok {
+		return MountCheck{}, errors.Errorf("no mount found for %s", path)
+	}
+
+	mc := MountCheck{
+		Path:       path,
+		MountPoint: mi.MountPoint,
+		Source:     mi.Source,
+		FSType:     mi.FSType,
+		// "ro" may be set on the mount, or on the whole filesystem
+		ReadOnly: mi.HasOption("ro"),
+		NoExec:   hasString(mi.Options, "noexec"),
+		NoSuid:   hasString(mi.Options, "nosuid"),
+	}
+
+	if mc.ReadOnly {
+		mc.Checks = append(mc.Checks, Check{
+			Name:   "ro",
+			Status: CheckBlocking,
+			Detail: fmt.Sprintf("%s (%s) is mounted read-only, nothing can be installed there", mi.MountPoint, mi.Source),
+			Remedy: "pick another install location, or remount it read-write",
+		})
+	}
+	if mc.NoExec {
+		mc.Checks = append(mc.Checks, Check{
+			Name:   "noexec",
+			Status: CheckBlocking,
+			Detail: fmt.Sprintf("%s (%s) is mounted noexec, programs installed there will fail to launch with \"permission denied\"", mi.MountPoint, mi.Source),
+			Remedy: fmt.Sprintf("pick another install location, or run `sudo mount -o remount,exec %s` (and remove noexec from /etc/fstab to make it permanent)", mi.MountPoint),
+		})
+	}
+	if mc.NoSuid {
+		mc.Checks = append(mc.Checks, Check{
+			Name:   "nosuid",
+			Status: CheckWarning,
+			Detail: fmt.Sprintf("%s (%s) is mounted nosuid, setuid helpers like Electron's chrome-sandbox won't work", mi.MountPoint, mi.Source),
+			Remedy: "apps that need them may have to run with their sandbox disabled",
+		})
+	}
+	return mc, nil
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package linox_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMountIn(t *testing.T) {
+	mounts, err := linox.ParseMountInfo(strings.NewReader(mountInfo))
+	assert.NoError(t, err)
+
+	mc, err := linox.CheckMountIn(mounts, "/home/user/Games")
+	assert.NoError(t, err)
+	assert.True(t, mc.OK())
+	assert.Empty(t, mc.Checks)
+	assert.Equal(t, "/", mc.MountPoint)
+	assert.Equal(t, "/dev/sda2", mc.Source)
+	assert.Equal(t, "ext4", mc.FSType)
+
+	mc, err = linox.CheckMountIn(mounts, "/proc/self")
+	assert.NoError(t, err)
+	assert.False(t, mc.OK())
+	assert.True(t, mc.NoExec)
+	assert.True(t, mc.NoSuid)
+	assert.False(t, mc.ReadOnly)
+	assert.Len(t, mc.Checks, 2)
+	assert.Equal(t, "noexec", mc.Checks[0].Name)
+	assert.Equal(t, linox.CheckBlocking, mc.Checks[0].Status)
+	assert.Contains(t, mc.Checks[0].Remedy, "remount,exec /proc")
+	assert.Equal(t, linox.CheckWarning, mc.Checks[1].Status)
+
+	mc, err = linox.CheckMountIn(mounts, "/mnt/ro/game")
+	assert.NoError(t, err)
+	assert.True(t, mc.ReadOnly)
+	assert.False(t, mc.NoExec)
+	assert.Equal(t, "/dev/sdb2", mc.Source)
+	assert.False(t, mc.OK())
+
+	// nosuid alone is only a warning
+	mc, err = linox.CheckMountIn(mounts, "/media/user/My Games/game")
+	assert.NoError(t, err)
+	assert.True(t, mc.NoSuid)
+	assert.True(t, mc.OK())
+
+	_, err = linox.CheckMountIn(nil, "/")
+	assert.Error(t, err)
+}
+
+func TestCheckMount(t *testing.T) {
+	mc, err := linox.CheckMount(t.TempDir() + "/not/yet/installed")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, mc.MountPoint)
+	assert.True(t, strings.HasSuffix(mc.Path, "/not/yet/installed"))
+}
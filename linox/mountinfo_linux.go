@@ -0,0 +1,225 @@
+package linox
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MountInfo is a single line of /proc/self/mountinfo, cf. proc(5)
+type MountInfo struct {
+	ID       int
+	ParentID int
+	// Major and Minor are the device numbers of the filesystem
+	Major int
+	Minor int
+	// Root is the directory of the filesystem that is mounted,
+	// which is "/" unless it's a bind mount
+	Root       string
+	MountPoint string
+	// Options are per-mount options, like "rw", "noexec" or "nosuid"
+	Options []string
+	// OptionalFields are tags like "shared:1" or "master:2"
+	OptionalFields []string
+	// FSType is the filesystem type, like "ext4", "fuseblk" or "fuse.sshfs"
+	FSType string
+	// Source is filesystem-specific, usually a device like "/dev/sda1"
+	Source string
+	// SuperOptions are per-filesystem options, like "errors=remount-ro"
+	SuperOptions []string
+}
+
+// HasOption returns true if opt is among the mount
+// options or the filesystem (super block) options
+func (mi MountInfo) HasOption(opt string) bool {
+	for _, o := range mi.Options {
+		if o == opt {
+			return true
+		}
+	}
+	for _, o := range mi.SuperOptions {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Mounts returns the mounts visible to the current process
+func Mounts() ([]MountInfo, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	return ParseMountInfo(f)
+}
+
+// ParseMountInfo parses the contents of /proc/[pid]/mountinfo
+func ParseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		mi, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mi)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return mounts, nil
+}
+
+// e.g. "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue"
+func parseMountInfoLine(line string) (MountInfo, error) {
+	var mi MountInfo
+
+	fields := strings.Fields(line)
+	sep := -1
+	for i, field := range fields {
+		if field == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 6 || len(fields) < sep+3 {
+		return mi, errors.Errorf("invalid mountinfo line %q", line)
+	}
+
+	var err error
+	mi.ID, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return mi, errors.Wrapf(err, "invalid mount ID in %q", line)
+	}
+	mi.ParentID, err = strconv.Atoi(fields[1])
+	if err != nil {
+		return mi, errors.Wrapf(err, "invalid parent ID in %q", line)
+	}
+
+	major, minor, ok := strings.Cut(fields[2], ":")
+	if !ok {
+		return mi, errors.Errorf("invalid device number in %q", line)
+	}
+	mi.Major, _ = strconv.Atoi(major)
+	mi.Minor, _ = strconv.Atoi(minor)
+
+	mi.Root = unescapeMountInfo(fields[3])
+	mi.MountPoint = unescapeMountInfo(fields[4])
+	mi.Options = strings.Split(fields[5], ",")
+	mi.OptionalFields = fields[6:sep]
+	mi.FSType = fields[sep+1]
+	mi.Source = unescapeMountInfo(fields[sep+2])
+	if len(fields) > sep+3 {
+		mi.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return mi, nil
+}
+
+// unescapeMountInfo decodes the octal escapes the kernel uses
+// for spaces, tabs, newlines and backslashes, like "\040"
+func unescapeMountInfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// FindMount returns the mount path is on, which is the last one
+// (mounts can be stacked) with the longest matching mount point.
+// path must be absolute and free of symlinks.
+func FindMount(mounts []MountInfo, path string) (MountInfo, bool) {
+	path = filepath.Clean(path)
+
+	var best MountInfo
+	found := false
+	for _, mi := range mounts {
+		if !isUnder(path, mi.MountPoint) {
+			continue
+		}
+		if !found || len(mi.MountPoint) >= len(best.MountPoint) {
+			best = mi
+			found = true
+		}
+	}
+	return best, found
+}
+
+// MountFor returns the mount path is on
+func MountFor(path string) (MountInfo, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return MountInfo{}, err
+	}
+
+	mounts, err := Mounts()
+	if err != nil {
+		return MountInfo{}, err
+	}
+
+	mi, ok := FindMount(mounts, resolved)
+	if !ok {
+		return MountInfo{}, errors.Errorf("no mount found for %s", path)
+	}
+	return mi, nil
+}
+
+// resolvePath makes path absolute and resolves symlinks. If path
+// doesn't exist yet, its closest existing parent is resolved instead.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", errors.WithStack(err)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", errors.WithStack(err)
+		}
+		rest = append([]string{filepath.Base(path)}, rest...)
+		path = parent
+	}
+}
+
+// isUnder returns true if path is dir, or inside of it
+func isUnder(path string, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
@@ -0,0 +1,75 @@
+package linox_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+const mountInfo = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw,errors=remount-ro
+23 22 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw
+45 22 0:40 / /tmp rw,nosuid,nodev,noexec shared:20 - tmpfs tmpfs rw,size=8152308k
+61 22 8:17 / /media/user/My\040Games rw,nosuid,nodev,relatime shared:33 - fuseblk /dev/sdb1 rw,user_id=0,group_id=0,allow_other
+62 22 8:18 / /mnt/ro ro,relatime shared:34 - vfat /dev/sdb2 rw,fmask=0022
+70 45 0:50 / /tmp rw,relatime shared:40 - tmpfs tmpfs rw
+`
+
+func TestParseMountInfo(t *testing.T) {
+	mounts, err := linox.ParseMountInfo(strings.NewReader(mountInfo))
+	assert.NoError(t, err)
+	assert.Len(t, mounts, 6)
+
+	root := mounts[0]
+	assert.Equal(t, 22, root.ID)
+	assert.Equal(t, 1, root.ParentID)
+	assert.Equal(t, 8, root.Major)
+	assert.Equal(t, 2, root.Minor)
+	assert.Equal(t, "/", root.MountPoint)
+	assert.Equal(t, "ext4", root.FSType)
+	assert.Equal(t, "/dev/sda2", root.Source)
+	assert.Equal(t, []string{"shared:1"}, root.OptionalFields)
+	assert.True(t, root.HasOption("errors=remount-ro"))
+	assert.False(t, root.HasOption("noexec"))
+
+	games := mounts[3]
+	assert.Equal(t, "/media/user/My Games", games.MountPoint)
+	assert.Equal(t, "fuseblk", games.FSType)
+
+	_, err = linox.ParseMountInfo(strings.NewReader("22 1 8:2 / /\n"))
+	assert.Error(t, err)
+}
+
+func TestFindMount(t *testing.T) {
+	mounts, err := linox.ParseMountInfo(strings.NewReader(mountInfo))
+	assert.NoError(t, err)
+
+	mi, ok := linox.FindMount(mounts, "/home/user/games")
+	assert.True(t, ok)
+	assert.Equal(t, "/", mi.MountPoint)
+
+	mi, ok = linox.FindMount(mounts, "/media/user/My Games/celeste/Celeste")
+	assert.True(t, ok)
+	assert.Equal(t, "/dev/sdb1", mi.Source)
+
+	// no false positives on prefixes
+	mi, ok = linox.FindMount(mounts, "/procfoo")
+	assert.True(t, ok)
+	assert.Equal(t, "/", mi.MountPoint)
+
+	// stacked mounts: the last one wins
+	mi, ok = linox.FindMount(mounts, "/tmp/foo")
+	assert.True(t, ok)
+	assert.Equal(t, 70, mi.ID)
+
+	_, ok = linox.FindMount(nil, "/")
+	assert.False(t, ok)
+}
+
+func TestMountFor(t *testing.T) {
+	mi, err := linox.MountFor(t.TempDir() + "/does/not/exist")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, mi.MountPoint)
+	assert.NotEmpty(t, mi.FSType)
+}
@@ -0,0 +1,299 @@
+package linox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// CheckStatus is the outcome of a single diagnostic check
+type CheckStatus string
+
+const (
+	CheckOK CheckStatus = "ok"
+	// CheckWarning means the check found something that may cause problems
+	CheckWarning CheckStatus = "warning"
+	// CheckBlocking means the check found something that definitely prevents the feature from working
+	CheckBlocking CheckStatus = "blocking"
+	// CheckUnknown means the check could not be performed
+	CheckUnknown CheckStatus = "unknown"
+)
+
+// Check is the result of a single diagnostic check
+type Check struct {
+	// Name identifies the check, usually the sysctl or file it looks at
+	Name   string
+	Status CheckStatus
+	// Detail explains what was found
+	Detail string
+	// Remedy explains how to fix it, if Status isn't CheckOK
+	Remedy string
+}
+
+// UserNamespaceReport is returned by DiagnoseUserNamespaces
+type UserNamespaceReport struct {
+	// Supported is true if unprivileged processes can create user
+	// namespaces, and have full capabilities inside of them.
+	Supported bool
+	Checks    []Check
+}
+
+// Problems returns the checks that didn't pass
+func (r UserNamespaceReport) Problems() []Check {
+	var res []Check
+	for _, c := range r.Checks {
+		if c.Status == CheckBlocking || c.Status == CheckWarning {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+func (r UserNamespaceReport) String() string {
+	var sb strings.Builder
+	if r.Supported {
+		sb.WriteString("unprivileged user namespaces are available")
+	} else {
+		sb.WriteString("unprivileged user namespaces are not available")
+	}
+	for _, c := range r.Problems() {
+		fmt.Fprintf(&sb, "\n- [%s] %s: %s", c.Status, c.Name, c.Detail)
+		if c.Remedy != "" {
+			fmt.Fprintf(&sb, " (%s)", c.Remedy)
+		}
+	}
+	return sb.String()
+}
+
+// cloneProbePath can never be executed, since /dev/null isn't a
+// directory. That lets ProbeCloneNewUser tell clone() failures apart
+// from exec() failures without depending on any binary being installed.
+const cloneProbePath = "/dev/null/ox-clone-probe"
+
+// ProbeCloneNewUser tries to start a child process in a new user
+// namespace. It returns nil if the kernel allowed it, or the error
+// clone() returned (EPERM, ENOSPC, EINVAL, etc.)
+func ProbeCloneNewUser() error {
+	attr := &syscall.ProcAttr{
+		Sys: &syscall.SysProcAttr{
+			Cloneflags: syscall.CLONE_NEWUSER,
+		},
+	}
+	pid, err := syscall.ForkExec(cloneProbePath, []string{cloneProbePath}, attr)
+	if err == nil {
+		// can't happen, but let's not leave a zombie behind
+		var ws syscall.WaitStatus
+		_, _ = syscall.Wait4(pid, &ws, 0, nil)
+		return nil
+	}
+	if errors.Is(err, syscall.ENOTDIR) || errors.Is(err, syscall.ENOENT) {
+		// clone() worked, only exec() failed, as intended
+		return nil
+	}
+	return err
+}
+
+// DiagnoseUserNamespaces explains whether, and why not, unprivileged
+// user namespaces are available on the running system.
+func DiagnoseUserNamespaces() UserNamespaceReport {
+	return DiagnoseUserNamespacesAt("/", os.Getenv, ProbeCloneNewUser)
+}
+
+// DiagnoseUserNamespacesAt runs the same checks as DiagnoseUserNamespaces,
+// reading /proc from root and the environment from getenv. If probe is
+// non-nil, it's used to actually attempt creating a user namespace.
+func DiagnoseUserNamespacesAt(root string, getenv func(key string) string, probe func() error) UserNamespaceReport {
+	checks := []Check{
+		checkUserNamespacesEnabled(root),
+		checkUnprivilegedUsernsClone(root),
+		checkMaxUserNamespaces(root),
+		checkAppArmorRestriction(root),
+		checkSeccomp(root),
+	}
+	if c, ok := checkSandboxEnvironment(root, getenv); ok {
+		checks = append(checks, c)
+	}
+
+	probeFailed := false
+	if probe != nil {
+		c := Check{Name: "clone(CLONE_NEWUSER)", Status: CheckOK, Detail: "creating a user namespace succeeded"}
+		if err := probe(); err != nil {
+			probeFailed = true
+			c.Status = CheckBlocking
+			c.Detail = fmt.Sprintf("creating a user namespace failed: %v", err)
+			switch {
+			case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EUSERS):
+				c.Remedy = "the user namespace limit was reached, see user.max_user_namespaces"
+			case errors.Is(err, syscall.EINVAL):
+				c.Remedy = "the kernel was built without user namespace support (CONFIG_USER_NS)"
+			case errors.Is(err, syscall.EPERM):
+				c.Remedy = "denied by a sysctl, a seccomp filter or a security module, see the other checks"
+			}
+		}
+		checks = append(checks, c)
+	}
+
+	supported := !probeFailed
+	for _, c := range checks {
+		if c.Status == CheckBlocking {
+			supported = false
+		}
+	}
+
+	return UserNamespaceReport{
+		Supported: supported,
+		Checks:    checks,
+	}
+}
+
+// readSysctl reads a sysctl from root's /proc/sys, as a trimmed string.
+func readSysctl(root string, name string) (string, error) {
+	path := filepath.Join(root, "/proc/sys", strings.ReplaceAll(name, ".", "/"))
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func checkUserNamespacesEnabled(root string) Check {
+	c := Check{Name: "CONFIG_USER_NS"}
+	if _, err := os.Stat(filepath.Join(root, "/proc/self/ns/user")); err != nil {
+		if os.IsNotExist(err) {
+			c.Status = CheckBlocking
+			c.Detail = "the kernel doesn't support user namespaces"
+			c.Remedy = "use a kernel built with CONFIG_USER_NS=y"
+			return c
+		}
+		c.Status = CheckUnknown
+		c.Detail = err.Error()
+		return c
+	}
+	c.Status = CheckOK
+	c.Detail = "the kernel supports user namespaces"
+	return c
+}
+
+func checkUnprivilegedUsernsClone(root string) Check {
+	const name = "kernel.unprivileged_userns_clone"
+	c := Check{Name: name}
+	value, err := readSysctl(root, name)
+	switch {
+	case os.IsNotExist(err):
+		// only exists on Debian-patched and some hardened kernels
+		c.Status = CheckOK
+		c.Detail = "not present (not a Debian-patched kernel)"
+	case err != nil:
+		c.Status = CheckUnknown
+		c.Detail = err.Error()
+	case value == "0":
+		c.Status = CheckBlocking
+		c.Detail = "unprivileged user namespaces are disabled"
+		c.Remedy = "run `sudo sysctl -w kernel.unprivileged_userns_clone=1`, and add it to /etc/sysctl.d/ to make it permanent"
+	default:
+		c.Status = CheckOK
+		c.Detail = fmt.Sprintf("set to %s", value)
+	}
+	return c
+}
+
+func checkMaxUserNamespaces(root string) Check {
+	const name = "user.max_user_namespaces"
+	c := Check{Name: name}
+	value, err := readSysctl(root, name)
+	switch {
+	case os.IsNotExist(err):
+		c.Status = CheckUnknown
+		c.Detail = "not present"
+	case err != nil:
+		c.Status = CheckUnknown
+		c.Detail = err.Error()
+	case value == "0":
+		c.Status = CheckBlocking
+		c.Detail = "no user namespaces may be created"
+		c.Remedy = "run `sudo sysctl -w user.max_user_namespaces=15000`, and add it to /etc/sysctl.d/ to make it permanent"
+	default:
+		c.Status = CheckOK
+		c.Detail = fmt.Sprintf("up to %s user namespaces", value)
+	}
+	return c
+}
+
+func checkAppArmorRestriction(root string) Check {
+	const name = "kernel.apparmor_restrict_unprivileged_userns"
+	c := Check{Name: name}
+	value, err := readSysctl(root, name)
+	switch {
+	case os.IsNotExist(err):
+		c.Status = CheckOK
+		c.Detail = "not present"
+	case err != nil:
+		c.Status = CheckUnknown
+		c.Detail = err.Error()
+	case value == "1":
+		// creating the namespace succeeds, but it has no capabilities,
+		// so probing with clone() alone doesn't catch this.
+		c.Status = CheckBlocking
+		c.Detail = "AppArmor only grants capabilities in user namespaces to confined applications (Ubuntu 23.10 and later)"
+		c.Remedy = "install an AppArmor profile that allows `userns` for the application, or run `sudo sysctl -w kernel.apparmor_restrict_unprivileged_userns=0`"
+	default:
+		c.Status = CheckOK
+		c.Detail = fmt.Sprintf("set to %s", value)
+	}
+	return c
+}
+
+func checkSeccomp(root string) Check {
+	c := Check{Name: "seccomp"}
+	f, err := os.Open(filepath.Join(root, "/proc/self/status"))
+	if err != nil {
+		c.Status = CheckUnknown
+		c.Detail = err.Error()
+		return c
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || key != "Seccomp" {
+			continue
+		}
+		// 0: disabled, 1: strict, 2: filter
+		if strings.TrimSpace(value) == "2" {
+			c.Status = CheckWarning
+			c.Detail = "a seccomp filter is active, it may deny clone(CLONE_NEWUSER)"
+			c.Remedy = "if running in a container, allow the clone and unshare syscalls in its seccomp profile"
+			return c
+		}
+		c.Status = CheckOK
+		c.Detail = "no seccomp filter"
+		return c
+	}
+	c.Status = CheckUnknown
+	c.Detail = "seccomp status not found"
+	return c
+}
+
+func checkSandboxEnvironment(root string, getenv func(key string) string) (Check, bool) {
+	ei := DetectEnvironment(root, getenv)
+	c := Check{Name: "environment", Status: CheckWarning}
+	switch {
+	case ei.Flatpak != nil:
+		c.Detail = "running in Flatpak, which doesn't allow creating user namespaces directly"
+		c.Remedy = "use flatpak-spawn or the Flatpak sandbox instead"
+	case ei.Snap != nil:
+		c.Detail = "running in a Snap, confinement may deny creating user namespaces"
+		c.Remedy = "check the snap's interfaces and confinement mode"
+	case ei.Container != nil:
+		c.Detail = fmt.Sprintf("running in a %s container, which usually denies creating user namespaces", ei.Container.Engine)
+		c.Remedy = "run the container with a seccomp profile that allows user namespaces"
+	default:
+		return Check{}, false
+	}
+	return c, true
+}
@@ -0,0 +1,117 @@
+package linox_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/ox/linox"
+	"github.com/stretchr/testify/assert"
+)
+
+func probeOK() error { return nil }
+
+func usernsFixture(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	all := map[string]string{
+		"proc/self/ns/user":                 "",
+		"proc/sys/user/max_user_namespaces": "63422\n",
+		"proc/self/status":                  "Name:\tox\nSeccomp:\t0\n",
+	}
+	for k, v := range files {
+		all[k] = v
+	}
+	writeFixture(t, root, all)
+	return root
+}
+
+func findCheck(report linox.UserNamespaceReport, name string) linox.Check {
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	return linox.Check{}
+}
+
+func TestDiagnoseUserNamespaces_Supported(t *testing.T) {
+	root := usernsFixture(t, nil)
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), probeOK)
+	assert.True(t, report.Supported)
+	assert.Empty(t, report.Problems())
+}
+
+func TestDiagnoseUserNamespaces_DebianSysctl(t *testing.T) {
+	root := usernsFixture(t, map[string]string{
+		"proc/sys/kernel/unprivileged_userns_clone": "0\n",
+	})
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), nil)
+	assert.False(t, report.Supported)
+	c := findCheck(report, "kernel.unprivileged_userns_clone")
+	assert.Equal(t, linox.CheckBlocking, c.Status)
+	assert.Contains(t, c.Remedy, "kernel.unprivileged_userns_clone=1")
+}
+
+func TestDiagnoseUserNamespaces_MaxUserNamespaces(t *testing.T) {
+	root := usernsFixture(t, map[string]string{
+		"proc/sys/user/max_user_namespaces": "0\n",
+	})
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), nil)
+	assert.False(t, report.Supported)
+	assert.Equal(t, linox.CheckBlocking, findCheck(report, "user.max_user_namespaces").Status)
+}
+
+func TestDiagnoseUserNamespaces_AppArmor(t *testing.T) {
+	root := usernsFixture(t, map[string]string{
+		"proc/sys/kernel/apparmor_restrict_unprivileged_userns": "1\n",
+	})
+	// clone() succeeds with the AppArmor restriction, the namespace
+	// just doesn't get any capabilities.
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), probeOK)
+	assert.False(t, report.Supported)
+	c := findCheck(report, "kernel.apparmor_restrict_unprivileged_userns")
+	assert.Equal(t, linox.CheckBlocking, c.Status)
+	assert.Contains(t, c.Remedy, "AppArmor profile")
+	assert.True(t, strings.Contains(report.String(), "apparmor_restrict_unprivileged_userns"))
+}
+
+func TestDiagnoseUserNamespaces_NoKernelSupport(t *testing.T) {
+	root := t.TempDir()
+	writeFixture(t, root, map[string]string{
+		"proc/self/status": "Seccomp:\t0\n",
+	})
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), nil)
+	assert.False(t, report.Supported)
+	assert.Equal(t, linox.CheckBlocking, findCheck(report, "CONFIG_USER_NS").Status)
+}
+
+func TestDiagnoseUserNamespaces_Container(t *testing.T) {
+	root := usernsFixture(t, map[string]string{
+		".dockerenv":       "",
+		"proc/self/status": "Seccomp:\t2\n",
+	})
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), func() error {
+		return syscall.EPERM
+	})
+	assert.False(t, report.Supported)
+	assert.Equal(t, linox.CheckWarning, findCheck(report, "seccomp").Status)
+
+	c := findCheck(report, "environment")
+	assert.Equal(t, linox.CheckWarning, c.Status)
+	assert.Contains(t, c.Detail, "docker")
+
+	c = findCheck(report, "clone(CLONE_NEWUSER)")
+	assert.Equal(t, linox.CheckBlocking, c.Status)
+	assert.NotEmpty(t, c.Remedy)
+	assert.Len(t, report.Problems(), 3)
+}
+
+func TestDiagnoseUserNamespaces_Warnings(t *testing.T) {
+	root := usernsFixture(t, map[string]string{
+		"proc/self/status": "Seccomp:\t2\n",
+	})
+	// warnings alone don't make it unsupported if the probe succeeds
+	report := linox.DiagnoseUserNamespacesAt(root, fakeEnv(nil), probeOK)
+	assert.True(t, report.Supported)
+	assert.Len(t, report.Problems(), 1)
+}
@@ -0,0 +1,302 @@
+package ox
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/pkg/errors"
+)
+
+// PreallocateStrategy is a way of reserving disk space for a file
+type PreallocateStrategy string
+
+const (
+	// PreallocateAuto uses fallocate, and falls back to
+	// zero-fill if the filesystem doesn't support it.
+	PreallocateAuto PreallocateStrategy = "auto"
+	// PreallocateFallocate allocates blocks without writing them,
+	// with fallocate(2) on Linux, F_PREALLOCATE on macOS and
+	// SetEndOfFile on Windows. It fails if the filesystem doesn't
+	// support it.
+	PreallocateFallocate PreallocateStrategy = "fallocate"
+	// PreallocatePosixFallocate behaves like posix_fallocate(3): it
+	// uses fallocate if possible, and otherwise writes a single zero
+	// byte in each block, which is much less I/O than zero-fill.
+	PreallocatePosixFallocate PreallocateStrategy = "posix_fallocate"
+	// PreallocateSparse only sets the file size, with ftruncate(2).
+	// No space is actually reserved, but it's instant.
+	PreallocateSparse PreallocateStrategy = "sparse"
+	// PreallocateZeroFill writes zeros up to the requested size.
+	// It works everywhere, but is slow for large files.
+	PreallocateZeroFill PreallocateStrategy = "zero-fill"
+)
+
+// PreallocateOptions configures PreallocateWithOptions
+type PreallocateOptions struct {
+	// Strategy defaults to PreallocateAuto
+	Strategy PreallocateStrategy
+	// SimulateFallocateNotSupported makes fallocate fail with
+	// ENOTSUP, to test fallbacks. It does nothing on Windows.
+	SimulateFallocateNotSupported bool
+
+	// Progress, if set, is called with the number of bytes
+	// preallocated so far, and the total to preallocate
+	Progress func(done int64, total int64)
+	// Consumer, if set, receives progress in the [0,1] interval
+	Consumer *state.Consumer
+
+	// ZeroFillConcurrency is how many chunks zero-fill writes in
+	// parallel. Defaults to DefaultZeroFillConcurrency
+	ZeroFillConcurrency int
+}
+
+// PreallocateResult describes what PreallocateWithOptions did
+type PreallocateResult struct {
+	// Strategy is the strategy that actually ran (never PreallocateAuto).
+	// It is empty if the file was already large enough.
+	Strategy PreallocateStrategy
+	// BytesWritten is how many bytes were written to disk, which
+	// is zero unless some form of zero-fill ran.
+	BytesWritten int64
+	// Duration is how long the whole operation took
+	Duration time.Duration
+}
+
+// Reserve `size` bytes of space for f, in the
+// quickest way possible. f must be opened with O_RDWR.
+func Preallocate(f *os.File, size int64) error {
+	_, err := PreallocateWithOptions(f, size, PreallocateOptions{})
+	return err
+}
+
+// PreallocateWithOptions grows f to `size` bytes using the given
+// strategy, and reports how it went. Files that are already at
+// least `size` bytes long are left alone. f must be opened with O_RDWR.
+//
+// Unless the strategy is PreallocateSparse, it returns an
+// InsufficientSpaceError without writing anything if there isn't
+// enough space available on the filesystem.
+func PreallocateWithOptions(f *os.File, size int64, opts PreallocateOptions) (*PreallocateResult, error) {
+	return PreallocateContext(context.Background(), f, size, opts)
+}
+
+// PreallocateContext is like PreallocateWithOptions, but stops when
+// ctx is done. Whenever it returns an error, whether it was cancelled
+// or not, f is truncated back to its original size.
+func PreallocateContext(ctx context.Context, f *os.File, size int64, opts PreallocateOptions) (*PreallocateResult, error) {
+	start := time.Now()
+	res := &PreallocateResult{}
+
+	currentSize, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if size > currentSize {
+		strategy := opts.Strategy
+		if strategy == "" {
+			strategy = PreallocateAuto
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if strategy != PreallocateSparse {
+			// fail before writing anything if it can't possibly fit
+			err = checkDiskSpace(f, uint64(size-currentSize))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		p := &preallocation{
+			ctx:         ctx,
+			f:           f,
+			currentSize: currentSize,
+			size:        size,
+			opts:        opts,
+			res:         res,
+		}
+		p.progress(0)
+		err = p.run(strategy)
+		if err != nil {
+			// a failed strategy may leave f partially written, or
+			// full-size but sparse, neither of which is useful
+			if terr := f.Truncate(currentSize); terr != nil {
+				return nil, errors.Wrapf(terr, "while truncating back to %v bytes after: %v", currentSize, err)
+			}
+			return nil, err
+		}
+		p.progress(size - currentSize)
+	}
+
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// zeroFillChunkSize is how much zero-fill writes at once. Chunks
+// are aligned on multiples of it, except for the first one.
+const zeroFillChunkSize = 4 * 1024 * 1024
+
+// DefaultZeroFillConcurrency is how many chunks zero-fill
+// writes in parallel, unless PreallocateOptions says otherwise
+const DefaultZeroFillConcurrency = 4
+
+var (
+	zeroChunkOnce sync.Once
+	zeroChunk     []byte
+)
+
+// zeroBuffer returns a zeroFillChunkSize buffer of zeros, shared by
+// everyone, so it must never be written to.
+func zeroBuffer() []byte {
+	zeroChunkOnce.Do(func() {
+		zeroChunk = make([]byte, zeroFillChunkSize)
+	})
+	return zeroChunk
+}
+
+// zeroFillWriteAt writes a chunk of zeros, tests
+// replace it to simulate write errors
+var zeroFillWriteAt = func(f *os.File, zeros []byte, offset int64) (int, error) {
+	return f.WriteAt(zeros, offset)
+}
+
+// preallocation holds the state of a single PreallocateContext call
+type preallocation struct {
+	ctx         context.Context
+	f           *os.File
+	currentSize int64
+	size        int64
+	opts        PreallocateOptions
+	res         *PreallocateResult
+}
+
+// progress reports that `done` bytes out of
+// size - currentSize have been preallocated
+func (p *preallocation) progress(done int64) {
+	total := p.size - p.currentSize
+	if p.opts.Progress != nil {
+		p.opts.Progress(done, total)
+	}
+	if total > 0 {
+		p.opts.Consumer.Progress(float64(done) / float64(total))
+	}
+}
+
+// zeroFill writes zeros to f from currentSize to size, with
+// up to opts.ZeroFillConcurrency writes in flight. Chunks complete
+// out of order, so progress only reports the contiguous range
+// written so far. On success, f's offset is at the end of the file.
+func (p *preallocation) zeroFill() error {
+	concurrency := p.opts.ZeroFillConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultZeroFillConcurrency
+	}
+	zeros := zeroBuffer()
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+
+	type chunk struct {
+		offset int64
+		length int64
+	}
+	type result struct {
+		offset  int64
+		written int64
+		err     error
+	}
+	chunks := make(chan chunk)
+	results := make(chan result)
+
+	go func() {
+		defer close(chunks)
+		offset := p.currentSize
+		for offset < p.size {
+			end := (offset/zeroFillChunkSize + 1) * zeroFillChunkSize
+			if end > p.size {
+				end = p.size
+			}
+			select {
+			case chunks <- chunk{offset, end - offset}:
+			case <-ctx.Done():
+				return
+			}
+			offset = end
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if ctx.Err() != nil {
+					continue
+				}
+				n, err := zeroFillWriteAt(p.f, zeros[:c.length], c.offset)
+				results <- result{c.offset, int64(n), err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// only this goroutine touches p.res and reports progress.
+	// contiguous is where the zeros written so far end, and
+	// pending holds chunks written past it, by offset.
+	var firstErr error
+	contiguous := p.currentSize
+	pending := make(map[int64]int64)
+	for r := range results {
+		p.res.BytesWritten += r.written
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = errors.WithStack(r.err)
+				cancel()
+			}
+			continue
+		}
+		if firstErr != nil {
+			continue
+		}
+
+		pending[r.offset] = r.offset + r.written
+		advanced := false
+		for {
+			end, ok := pending[contiguous]
+			if !ok {
+				break
+			}
+			delete(pending, contiguous)
+			contiguous = end
+			advanced = true
+		}
+		if advanced {
+			p.progress(contiguous - p.currentSize)
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := p.ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// zero-fill used to write sequentially through f, leaving its
+	// offset at the end of the file, which callers may rely on
+	if _, err := p.f.Seek(p.size, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
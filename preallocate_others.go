@@ -3,7 +3,6 @@
 package ox
 
 import (
-	"io"
 	"os"
 	"syscall"
 
@@ -11,64 +10,129 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Deprecated: use PreallocateOptions.SimulateFallocateNotSupported instead
 var SIMULATE_FALLOCATE_NOT_SUPPORTED = false
 
 func Fallocate(file *os.File, offset int64, length int64) error {
-	if SIMULATE_FALLOCATE_NOT_SUPPORTED {
+	return fallocateWithOptions(file, offset, length, PreallocateOptions{})
+}
+
+func fallocateWithOptions(file *os.File, offset int64, length int64, opts PreallocateOptions) error {
+	if SIMULATE_FALLOCATE_NOT_SUPPORTED || opts.SimulateFallocateNotSupported {
 		return syscall.ENOTSUP
 	} else {
 		return fallocate.Fallocate(file, offset, length)
 	}
 }
 
-// Reserve `size` bytes of space for f, in the
-// quickest way possible. f must be opened with O_RDWR.
-func Preallocate(f *os.File, size int64) error {
-	currentSize, err := f.Seek(0, io.SeekEnd)
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
+func (p *preallocation) run(strategy PreallocateStrategy) error {
+	f, currentSize, size, res := p.f, p.currentSize, p.size, p.res
 	remaining := size - currentSize
-	if remaining <= 0 {
+
+	switch strategy {
+	case PreallocateAuto:
+		err := fallocateWithOptions(f, currentSize, remaining, p.opts)
+		if err == nil {
+			res.Strategy = PreallocateFallocate
+			return nil
+		}
+		if !errors.Is(err, syscall.ENOTSUP) {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with fallocate", size)
+		}
+
+		// as of July 2020, we've seen this error condition happpen
+		// on Linux with NTFS, eCryptFS, and ZFS partitions. ext* are fine.
+
+		// this is the slower fallback:
+		res.Strategy = PreallocateZeroFill
+		err = p.zeroFill()
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with fallback", size)
+		}
+		return nil
+	case PreallocateFallocate:
+		res.Strategy = PreallocateFallocate
+		err := fallocateWithOptions(f, currentSize, remaining, p.opts)
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with fallocate", size)
+		}
+		return nil
+	case PreallocatePosixFallocate:
+		res.Strategy = PreallocatePosixFallocate
+		err := fallocateWithOptions(f, currentSize, remaining, p.opts)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, syscall.ENOTSUP) {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with posix_fallocate", size)
+		}
+		err = p.touchBlocks()
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with posix_fallocate", size)
+		}
+		return nil
+	case PreallocateSparse:
+		res.Strategy = PreallocateSparse
+		err := f.Truncate(size)
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with ftruncate", size)
+		}
+		return nil
+	case PreallocateZeroFill:
+		res.Strategy = PreallocateZeroFill
+		err := p.zeroFill()
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with zero-fill", size)
+		}
 		return nil
 	}
+	return errors.Errorf("unknown preallocation strategy %q", strategy)
+}
 
-	err = Fallocate(f, currentSize, remaining)
-	if err != nil {
-		if errors.Is(err, syscall.ENOTSUP) {
-			// as of July 2020, we've seen this error condition happpen
-			// on Linux with NTFS, eCryptFS, and ZFS partitions. ext* are fine.
+// touchBlocks writes a zero byte in every filesystem block between
+// currentSize and size, like glibc's posix_fallocate emulation does.
+// Bytes past currentSize are zero anyway, so only allocation changes.
+func (p *preallocation) touchBlocks() error {
+	f, currentSize, size := p.f, p.currentSize, p.size
 
-			// this is the slower fallback:
-			_, err = f.Seek(currentSize, io.SeekStart)
-			if err != nil {
-				return errors.Wrapf(err, "while pre-allocating %v bytes with fallback", size)
-			}
+	blockSize := int64(4096)
+	if stats, err := f.Stat(); err == nil {
+		if st, ok := stats.Sys().(*syscall.Stat_t); ok && st.Blksize > 0 {
+			blockSize = int64(st.Blksize)
+		}
+	}
 
-			_, err = io.Copy(f, io.LimitReader(&zeroReader{}, remaining))
-			if err != nil {
-				return errors.Wrapf(err, "while pre-allocating %v bytes with fallback", size)
+	zero := []byte{0}
+	// start at the first block boundary after currentSize, the
+	// block currentSize is in is already allocated (if not empty)
+	offset := (currentSize + blockSize - 1) / blockSize * blockSize
+	for i := 0; offset < size; offset += blockSize {
+		if i++; i%256 == 0 {
+			if err := p.ctx.Err(); err != nil {
+				return errors.WithStack(err)
 			}
-
-			return nil
+			p.progress(offset - currentSize)
 		}
 
+		n, err := f.WriteAt(zero, offset)
+		p.res.BytesWritten += int64(n)
 		if err != nil {
-			return errors.Wrapf(err, "while pre-allocating %v bytes with fallocate", size)
+			return errors.WithStack(err)
 		}
 	}
 
+	// make sure the file ends up exactly `size` bytes long
+	if (size-1)%blockSize != 0 {
+		n, err := f.WriteAt(zero, size-1)
+		p.res.BytesWritten += int64(n)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	return nil
 }
 
-type zeroReader struct{}
-
-var _ io.Reader = (*zeroReader)(nil)
-
-func (zr *zeroReader) Read(p []byte) (int, error) {
-	for i := 0; i < len(p); i++ {
-		p[i] = 0
-	}
-	return len(p), nil
+// probeFallocate returns true if fallocate works for f
+func probeFallocate(f *os.File) bool {
+	return fallocateWithOptions(f, 0, 4096, PreallocateOptions{}) == nil
 }
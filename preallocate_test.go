@@ -1,12 +1,16 @@
 package ox_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"testing"
 
+	"github.com/itchio/headway/state"
 	"github.com/itchio/ox"
 	"github.com/stretchr/testify/assert"
 )
@@ -64,3 +68,322 @@ func must(err error) {
 		panic(fmt.Sprintf("%+v", err))
 	}
 }
+
+func Test_PreallocateWithOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	strategies := []ox.PreallocateStrategy{
+		ox.PreallocateAuto,
+		ox.PreallocateFallocate,
+		ox.PreallocatePosixFallocate,
+		ox.PreallocateSparse,
+		ox.PreallocateZeroFill,
+	}
+	for _, strategy := range strategies {
+		f, err := ioutil.TempFile("", "")
+		must(err)
+
+		_, err = f.Write([]byte("hello"))
+		must(err)
+
+		const size = 64*1024 + 123
+		res, err := ox.PreallocateWithOptions(f, size, ox.PreallocateOptions{Strategy: strategy})
+		must(err)
+		assert.NotEqual(ox.PreallocateAuto, res.Strategy, "%s", strategy)
+		if strategy != ox.PreallocateAuto && runtime.GOOS != "windows" {
+			assert.Equal(strategy, res.Strategy)
+		}
+		if strategy == ox.PreallocateZeroFill {
+			assert.EqualValues(size-5, res.BytesWritten)
+		}
+
+		s, err := f.Stat()
+		must(err)
+		assert.EqualValues(size, s.Size(), "%s", strategy)
+
+		buf := make([]byte, 5)
+		_, err = f.ReadAt(buf, 0)
+		must(err)
+		assert.Equal("hello", string(buf), "%s", strategy)
+
+		// already large enough
+		res, err = ox.PreallocateWithOptions(f, 1024, ox.PreallocateOptions{Strategy: strategy})
+		must(err)
+		assert.Empty(res.Strategy)
+
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func Test_PreallocateWithOptions_Fallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fallocate is never simulated on Windows")
+	}
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	opts := ox.PreallocateOptions{SimulateFallocateNotSupported: true}
+	res, err := ox.PreallocateWithOptions(f, 4096, opts)
+	must(err)
+	assert.Equal(ox.PreallocateZeroFill, res.Strategy)
+	assert.EqualValues(4096, res.BytesWritten)
+
+	opts.Strategy = ox.PreallocateFallocate
+	_, err = ox.PreallocateWithOptions(f, 8192, opts)
+	assert.Error(err)
+
+	opts.Strategy = ox.PreallocatePosixFallocate
+	res, err = ox.PreallocateWithOptions(f, 3*4096+1, opts)
+	must(err)
+	assert.Equal(ox.PreallocatePosixFallocate, res.Strategy)
+	assert.True(res.BytesWritten > 0 && res.BytesWritten < 4096, "wrote %d bytes", res.BytesWritten)
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(3*4096+1, s.Size())
+
+	opts.Strategy = "bogus"
+	_, err = ox.PreallocateWithOptions(f, 1<<20, opts)
+	assert.Error(err)
+}
+
+func Test_PreallocateContext(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	var lastDone, lastTotal int64
+	var alphas []float64
+	opts := ox.PreallocateOptions{
+		Strategy: ox.PreallocateZeroFill,
+		Progress: func(done int64, total int64) {
+			assert.True(done >= lastDone, "progress should not go backwards")
+			lastDone, lastTotal = done, total
+		},
+		Consumer: &state.Consumer{
+			OnProgress: func(alpha float64) {
+				alphas = append(alphas, alpha)
+			},
+		},
+	}
+	const size = 3*1024*1024 + 17
+	res, err := ox.PreallocateContext(context.Background(), f, size, opts)
+	must(err)
+	assert.EqualValues(size, res.BytesWritten)
+	assert.EqualValues(size, lastDone)
+	assert.EqualValues(size, lastTotal)
+	assert.True(len(alphas) > 2)
+	assert.Equal(0.0, alphas[0])
+	assert.Equal(1.0, alphas[len(alphas)-1])
+}
+
+func Test_PreallocateContext_Cancel(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = f.Write([]byte("hello"))
+	must(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := ox.PreallocateOptions{
+		Strategy: ox.PreallocateZeroFill,
+		Progress: func(done int64, total int64) {
+			if done > 0 {
+				cancel()
+			}
+		},
+	}
+	_, err = ox.PreallocateContext(ctx, f, 64*1024*1024, opts)
+	assert.Error(err)
+	assert.True(errors.Is(err, context.Canceled))
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(5, s.Size(), "file should be truncated back to its original size")
+
+	// already cancelled: nothing happens
+	_, err = ox.PreallocateContext(ctx, f, 1024, ox.PreallocateOptions{})
+	assert.True(errors.Is(err, context.Canceled))
+	s, err = f.Stat()
+	must(err)
+	assert.EqualValues(5, s.Size())
+}
+
+func Test_PreallocateZeroFillError(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = f.Write([]byte("hello"))
+	must(err)
+
+	// the third chunk fails, while others may already be written
+	failAt := int64(8 * mib)
+	restore := ox.SetZeroFillWriteAt(func(f *os.File, zeros []byte, offset int64) (int, error) {
+		if offset == failAt {
+			return 0, errors.New("simulated write error")
+		}
+		return f.WriteAt(zeros, offset)
+	})
+	defer restore()
+
+	var reported []int64
+	opts := ox.PreallocateOptions{
+		Strategy:            ox.PreallocateZeroFill,
+		ZeroFillConcurrency: 4,
+		Progress: func(done int64, total int64) {
+			reported = append(reported, done)
+		},
+	}
+	_, err = ox.PreallocateWithOptions(f, 16*mib, opts)
+	assert.Error(err)
+
+	for i, done := range reported {
+		assert.True(done <= failAt-5, "progress should stop before the failed chunk, got %v", done)
+		if i > 0 {
+			assert.True(done >= reported[i-1], "progress should never go backwards")
+		}
+	}
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(5, s.Size(), "file should be truncated back to its original size")
+
+	contents, err := ioutil.ReadAll(io.NewSectionReader(f, 0, 16*mib))
+	must(err)
+	assert.Equal("hello", string(contents))
+}
+
+func Test_PreallocateZeroFillOffset(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = f.Write([]byte("hello"))
+	must(err)
+
+	// like the sequential zero-fill it replaced, writes
+	// after it go at the end of the file
+	size := int64(9*mib + 3)
+	_, err = ox.PreallocateWithOptions(f, size, ox.PreallocateOptions{
+		Strategy:            ox.PreallocateZeroFill,
+		ZeroFillConcurrency: 4,
+	})
+	must(err)
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	must(err)
+	assert.EqualValues(size, offset)
+
+	_, err = f.Write([]byte("!"))
+	must(err)
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(size+1, s.Size())
+
+	buf := make([]byte, 6)
+	_, err = f.ReadAt(buf[:5], 0)
+	must(err)
+	_, err = f.ReadAt(buf[5:], size)
+	must(err)
+	assert.Equal("hello!", string(buf))
+}
+
+func Test_PreallocateZeroFillConcurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, concurrency := range []int{1, 3, 16} {
+		f, err := ioutil.TempFile("", "")
+		must(err)
+
+		// start unaligned, to exercise the first, partial chunk
+		_, err = f.Write([]byte("hello"))
+		must(err)
+
+		const size = 10*1024*1024 + 321
+		res, err := ox.PreallocateWithOptions(f, size, ox.PreallocateOptions{
+			Strategy:            ox.PreallocateZeroFill,
+			ZeroFillConcurrency: concurrency,
+		})
+		must(err)
+		assert.EqualValues(size-5, res.BytesWritten)
+
+		contents, err := ioutil.ReadFile(f.Name())
+		must(err)
+		assert.EqualValues(size, len(contents))
+		assert.Equal("hello", string(contents[:5]))
+		for i, b := range contents[5:] {
+			if b != 0 {
+				assert.Fail("non-zero byte", "at offset %d", i+5)
+				break
+			}
+		}
+
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// the single-threaded fallback Preallocate used to have, for comparison
+type byteByByteZeroReader struct{}
+
+func (zr *byteByByteZeroReader) Read(p []byte) (int, error) {
+	for i := 0; i < len(p); i++ {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func Benchmark_ZeroFill(b *testing.B) {
+	const size = 64 * 1024 * 1024
+
+	run := func(b *testing.B, fill func(f *os.File)) {
+		b.SetBytes(size)
+		for i := 0; i < b.N; i++ {
+			f, err := ioutil.TempFile("", "")
+			must(err)
+			fill(f)
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+
+	b.Run("io.Copy", func(b *testing.B) {
+		run(b, func(f *os.File) {
+			_, err := io.Copy(f, io.LimitReader(&byteByByteZeroReader{}, size))
+			must(err)
+		})
+	})
+
+	for _, concurrency := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			run(b, func(f *os.File) {
+				_, err := ox.PreallocateWithOptions(f, size, ox.PreallocateOptions{
+					Strategy:            ox.PreallocateZeroFill,
+					ZeroFillConcurrency: concurrency,
+				})
+				must(err)
+			})
+		})
+	}
+}
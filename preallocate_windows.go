@@ -11,11 +11,47 @@ import (
 )
 
 // Note: this does nothing on Windows
+//
+// Deprecated: use PreallocateOptions.SimulateFallocateNotSupported instead
 var SIMULATE_FALLOCATE_NOT_SUPPORTED = false
 
-// Reserve `size` bytes of space for f, in the
-// quickest way possible. f must be opened with O_RDWR.
-func Preallocate(f *os.File, size int64) error {
+func (p *preallocation) run(strategy PreallocateStrategy) error {
+	f, size, res := p.f, p.size, p.res
+
+	switch strategy {
+	case PreallocateAuto, PreallocateFallocate, PreallocatePosixFallocate:
+		// NTFS allocates clusters on SetEndOfFile, and
+		// zeroes them lazily, so there's no need to fall back.
+		res.Strategy = PreallocateFallocate
+		err := setEndOfFile(f, size)
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with SetEndOfFile", size)
+		}
+		return nil
+	case PreallocateSparse:
+		res.Strategy = PreallocateSparse
+		var bytesReturned uint32
+		err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_SPARSE, nil, 0, nil, 0, &bytesReturned, nil)
+		if err != nil {
+			return errors.Wrapf(err, "while marking file as sparse")
+		}
+		err = setEndOfFile(f, size)
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with SetEndOfFile", size)
+		}
+		return nil
+	case PreallocateZeroFill:
+		res.Strategy = PreallocateZeroFill
+		err := p.zeroFill()
+		if err != nil {
+			return errors.Wrapf(err, "while pre-allocating %v bytes with zero-fill", size)
+		}
+		return nil
+	}
+	return errors.Errorf("unknown preallocation strategy %q", strategy)
+}
+
+func setEndOfFile(f *os.File, size int64) error {
 	_, err := f.Seek(size, io.SeekStart)
 	if err != nil {
 		return errors.WithStack(err)
@@ -28,3 +64,9 @@ func Preallocate(f *os.File, size int64) error {
 
 	return nil
 }
+
+// probeFallocate returns true, since SetEndOfFile
+// allocates space on all Windows filesystems
+func probeFallocate(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,119 @@
+package ox
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ResizeOptions configures Resize
+type ResizeOptions struct {
+	// Preallocate configures how the file is extended
+	Preallocate PreallocateOptions
+	// KeepAllocated, when shrinking, allocates again the ranges past
+	// the new end of the file that were allocated before truncating it,
+	// so growing it back later doesn't need to allocate them again.
+	// Sparse ranges stay sparse. By default, blocks are released.
+	// It uses FileExtents and FALLOC_FL_KEEP_SIZE, so it only does
+	// anything on Linux, and only on a best-effort basis.
+	KeepAllocated bool
+}
+
+// ResizeResult describes what Resize did
+type ResizeResult struct {
+	// PreviousSize is the size of the file before resizing
+	PreviousSize int64
+	// Preallocate is set if the file was extended
+	Preallocate *PreallocateResult
+	// KeptAllocated is true if KeepAllocated was requested, the file
+	// was shrunk, and the ranges past the end that were allocated
+	// before truncating were allocated again. If it's false, they
+	// may have been released.
+	KeptAllocated bool
+}
+
+// Resize truncates or extends f to exactly `size` bytes. Extending
+// uses PreallocateWithOptions, so the new space is allocated in the
+// fastest way available. f must be opened with O_RDWR.
+//
+// f's offset is left where it was, or at the new end of
+// the file if that's past it.
+func Resize(f *os.File, size int64, opts ResizeOptions) (*ResizeResult, error) {
+	if size < 0 {
+		return nil, errors.Errorf("invalid size %v", size)
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if offset > size {
+		offset = size
+	}
+	defer f.Seek(offset, io.SeekStart)
+
+	currentSize, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res := &ResizeResult{PreviousSize: currentSize}
+
+	switch {
+	case size > currentSize:
+		res.Preallocate, err = PreallocateWithOptions(f, size, opts.Preallocate)
+		if err != nil {
+			return nil, err
+		}
+	case size < currentSize:
+		var allocated []Extent
+		canKeep := false
+		if opts.KeepAllocated {
+			allocated, err = allocatedPast(f, size)
+			canKeep = err == nil
+		}
+
+		err = f.Truncate(size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while truncating to %v bytes", size)
+		}
+
+		if canKeep {
+			// the file is already shrunk, so failing to allocate again
+			// isn't an error: the blocks are gone, which is what
+			// happens by default anyway.
+			res.KeptAllocated = true
+			for _, e := range allocated {
+				if fallocateKeepSize(f, e.Offset, e.Length) != nil {
+					res.KeptAllocated = false
+					break
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// allocatedPast returns the allocated extents of f past offset,
+// clipped to start at offset
+func allocatedPast(f *os.File, offset int64) ([]Extent, error) {
+	em, err := FileExtents(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocated []Extent
+	for _, e := range em.Extents {
+		end := e.Offset + e.Length
+		if e.Hole || end <= offset {
+			continue
+		}
+		if e.Offset < offset {
+			e.Length = end - offset
+			e.Offset = offset
+		}
+		allocated = append(allocated, e)
+	}
+	return allocated, nil
+}
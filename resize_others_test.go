@@ -0,0 +1,85 @@
+//go:build !windows
+
+package ox_test
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResizeKeepAllocated(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	allocated := func() int64 {
+		s, err := f.Stat()
+		must(err)
+		st, ok := s.Sys().(*syscall.Stat_t)
+		if !ok {
+			t.Skip("no block counts on this platform")
+		}
+		return int64(st.Blocks) * 512
+	}
+
+	_, err = ox.Resize(f, 4*mib, ox.ResizeOptions{
+		Preallocate: ox.PreallocateOptions{Strategy: ox.PreallocateZeroFill},
+	})
+	must(err)
+	if allocated() < 4*mib {
+		// compressing or deduplicating filesystems store zeros for free
+		t.Skip("zero-fill didn't allocate blocks here")
+	}
+
+	res, err := ox.Resize(f, mib, ox.ResizeOptions{KeepAllocated: true})
+	must(err)
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(mib, s.Size())
+
+	if runtime.GOOS != "linux" {
+		assert.False(res.KeptAllocated)
+		return
+	}
+	if !res.KeptAllocated {
+		t.Skip("FALLOC_FL_KEEP_SIZE not supported here")
+	}
+	assert.True(allocated() >= 4*mib, "blocks past the end should still be allocated")
+
+	_, err = ox.Resize(f, mib/2, ox.ResizeOptions{})
+	must(err)
+	assert.True(allocated() < 4*mib, "blocks past the end should be released")
+}
+
+func Test_ResizeKeepAllocatedSparse(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	_, err = ox.Resize(f, 64*mib, ox.ResizeOptions{
+		Preallocate: ox.PreallocateOptions{Strategy: ox.PreallocateSparse},
+	})
+	must(err)
+
+	_, err = ox.Resize(f, mib, ox.ResizeOptions{KeepAllocated: true})
+	must(err)
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(mib, s.Size())
+	if st, ok := s.Sys().(*syscall.Stat_t); ok {
+		assert.True(int64(st.Blocks)*512 < mib, "sparse ranges shouldn't get allocated")
+	}
+}
@@ -0,0 +1,99 @@
+package ox_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Resize(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	assertSize := func(expected int64) {
+		s, err := f.Stat()
+		must(err)
+		assert.Equal(expected, s.Size())
+	}
+
+	res, err := ox.Resize(f, 2*mib, ox.ResizeOptions{})
+	must(err)
+	assert.EqualValues(0, res.PreviousSize)
+	if assert.NotNil(res.Preallocate) {
+		assert.NotEmpty(res.Preallocate.Strategy)
+	}
+	assertSize(2 * mib)
+
+	_, err = f.WriteAt([]byte("hello"), 0)
+	must(err)
+
+	res, err = ox.Resize(f, 5, ox.ResizeOptions{})
+	must(err)
+	assert.EqualValues(2*mib, res.PreviousSize)
+	assert.Nil(res.Preallocate)
+	assert.False(res.KeptAllocated)
+	assertSize(5)
+
+	// same size: nothing to do
+	res, err = ox.Resize(f, 5, ox.ResizeOptions{})
+	must(err)
+	assert.Nil(res.Preallocate)
+	assertSize(5)
+
+	buf := make([]byte, 5)
+	_, err = f.ReadAt(buf, 0)
+	must(err)
+	assert.Equal("hello", string(buf))
+
+	_, err = ox.Resize(f, -1, ox.ResizeOptions{})
+	assert.Error(err)
+}
+
+func Test_ResizeOffset(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	offset := func() int64 {
+		o, err := f.Seek(0, io.SeekCurrent)
+		must(err)
+		return o
+	}
+
+	_, err = f.Write([]byte("hello"))
+	must(err)
+
+	// growing leaves the offset alone
+	_, err = ox.Resize(f, mib, ox.ResizeOptions{})
+	must(err)
+	assert.EqualValues(5, offset())
+
+	// so does shrinking, if it's still inside the file
+	_, err = f.Seek(100, io.SeekStart)
+	must(err)
+	_, err = ox.Resize(f, 200, ox.ResizeOptions{})
+	must(err)
+	assert.EqualValues(100, offset())
+
+	// otherwise it ends up at the new end of the file
+	_, err = ox.Resize(f, 5, ox.ResizeOptions{})
+	must(err)
+	assert.EqualValues(5, offset())
+
+	_, err = f.Write([]byte(", world"))
+	must(err)
+	contents, err := ioutil.ReadFile(f.Name())
+	must(err)
+	assert.Equal("hello, world", string(contents))
+}
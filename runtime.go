@@ -2,10 +2,7 @@ package ox
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"runtime"
-	"strings"
+	"sync"
 )
 
 type Platform string
@@ -15,14 +12,23 @@ const (
 	PlatformOSX     Platform = "osx"
 	PlatformWindows Platform = "windows"
 	PlatformLinux   Platform = "linux"
+	PlatformAndroid Platform = "android"
+	PlatformFreeBSD Platform = "freebsd"
 	PlatformUnknown Platform = "unknown"
 )
 
 // Runtime describes an os-arch combo in a convenient way
 type Runtime struct {
-	Platform Platform `json:"platform"`
-	Is64     bool     `json:"is64"`
-	Architecture string `json:"arch,omitempty"` // "amd64", "arm64", "386", "arm"
+	Platform     Platform `json:"platform"`
+	Is64         bool     `json:"is64"`
+	Architecture string   `json:"arch,omitempty"` // GOARCH format: "amd64", "arm64", "386", "arm", "riscv64", etc.
+
+	// KernelArchitecture and UserlandArchitecture are only set
+	// when detecting the host on Linux, where they can differ, for example
+	// an arm64 kernel with an armhf userland. Architecture is then set to
+	// the userland's, as that's what builds need to match.
+	KernelArchitecture   string `json:"kernelArch,omitempty"`
+	UserlandArchitecture string `json:"userlandArch,omitempty"`
 }
 
 type Runtimes []Runtime
@@ -43,16 +49,24 @@ func (r Runtime) String() string {
 	} else {
 		arch = "32-bit"
 	}
-	var platform = "Unknown"
+	return fmt.Sprintf("%s %s", arch, r.platformName())
+}
+
+func (r Runtime) platformName() string {
 	switch r.Platform {
 	case PlatformLinux:
-		platform = "Linux"
+		return "Linux"
 	case PlatformOSX:
-		platform = "macOS"
+		return "macOS"
 	case PlatformWindows:
-		platform = "Windows"
+		return "Windows"
+	case PlatformAndroid:
+		return "Android"
+	case PlatformFreeBSD:
+		return "FreeBSD"
+	default:
+		return "Unknown"
 	}
-	return fmt.Sprintf("%s %s", arch, platform)
 }
 
 // OS returns the operating system in GOOS format
@@ -64,12 +78,18 @@ func (r Runtime) OS() string {
 		return "darwin"
 	case PlatformWindows:
 		return "windows"
+	case PlatformAndroid:
+		return "android"
+	case PlatformFreeBSD:
+		return "freebsd"
 	default:
 		return "unknown"
 	}
 }
 
-// Arch returns the architecture in GOARCH format
+// Arch returns the architecture in GOARCH format.
+// If Architecture is empty, it falls back to "amd64" or "386"
+// depending on Is64, like Runtime.UnmarshalJSON does for legacy records.
 func (r Runtime) Arch() string {
 	if r.Architecture != "" {
 		return r.Architecture
@@ -81,36 +101,86 @@ func (r Runtime) Arch() string {
 	return "386"
 }
 
+// Equals returns true if both runtimes have the same platform and
+// word size. It ignores the architecture, so linux/amd64 and linux/arm64
+// are equal: see StrictEquals.
 func (r Runtime) Equals(other Runtime) bool {
 	return r.Is64 == other.Is64 && r.Platform == other.Platform
 }
 
-var cachedRuntime *Runtime
+// StrictEquals returns true if both runtimes have the
+// same platform and architecture (as returned by Arch).
+func (r Runtime) StrictEquals(other Runtime) bool {
+	return r.Platform == other.Platform && r.Arch() == other.Arch()
+}
+
+var (
+	currentRuntimeOnce sync.Once
+	currentRuntime     Runtime
+	currentArch        ArchDetection
+)
+
+func detectCurrentRuntime() {
+	currentRuntimeOnce.Do(func() {
+		d := NewRuntimeDetector()
+		currentArch = d.DetectArchWithSource()
+		currentRuntime = d.runtimeForArch(currentArch.Arch)
+	})
+}
 
+// CurrentRuntime returns the Runtime of the host. It is only
+// detected once, and is safe to call from multiple goroutines.
 func CurrentRuntime() Runtime {
-	if cachedRuntime == nil {
-		arch := detectArch()
-		is64 := arch == "amd64" || arch == "arm64"
-
-		var platform Platform
-		switch runtime.GOOS {
-		case "linux":
-			platform = PlatformLinux
-		case "darwin":
-			platform = PlatformOSX
-		case "windows":
-			platform = PlatformWindows
-		default:
-			platform = PlatformUnknown
-		}
+	detectCurrentRuntime()
+	return currentRuntime
+}
 
-		cachedRuntime = &Runtime{
-			Is64:         is64,
-			Platform:     platform,
-			Architecture: arch,
-		}
+// CurrentArchDetection returns details on how the architecture
+// of CurrentRuntime was detected.
+func CurrentArchDetection() ArchDetection {
+	detectCurrentRuntime()
+	return currentArch
+}
+
+// platformFromGOOS maps a GOOS value to a Platform
+func platformFromGOOS(goos string) Platform {
+	switch goos {
+	case "linux":
+		return PlatformLinux
+	case "darwin":
+		return PlatformOSX
+	case "windows":
+		return PlatformWindows
+	case "android":
+		return PlatformAndroid
+	case "freebsd":
+		return PlatformFreeBSD
+	default:
+		return PlatformUnknown
 	}
-	return *cachedRuntime
+}
+
+// archWordSizes maps the architectures we know about, in GOARCH
+// format, to their word size in bits
+var archWordSizes = map[string]int{
+	"amd64":    64,
+	"arm64":    64,
+	"riscv64":  64,
+	"loong64":  64,
+	"ppc64le":  64,
+	"ppc64":    64,
+	"s390x":    64,
+	"mips64le": 64,
+	"mips64":   64,
+	"386":      32,
+	"arm":      32,
+	"mipsle":   32,
+	"mips":     32,
+}
+
+// isArch64 returns true if arch (in GOARCH format) is a 64-bit architecture
+func isArch64(arch string) bool {
+	return archWordSizes[arch] == 64
 }
 
 // archMapping maps OS-reported architecture names to GOARCH format
@@ -130,6 +200,15 @@ var archMapping = map[string]string{
 	// 32-bit arm
 	"armv7l": "arm",
 	"armv6l": "arm",
+	"armv8l": "arm", // 32-bit userland on ARMv8
+	// other 64-bit architectures
+	"riscv64":     "riscv64",
+	"loongarch64": "loong64",
+	"loong64":     "loong64",
+	"ppc64le":     "ppc64le",
+	"ppc64":       "ppc64",
+	"s390x":       "s390x",
+	"mips64":      "mips64",
 }
 
 // MapArchitecture converts an OS-reported architecture name to GOARCH format.
@@ -137,86 +216,3 @@ var archMapping = map[string]string{
 func MapArchitecture(osArch string) string {
 	return archMapping[osArch]
 }
-
-var hasDeterminedArch = false
-var cachedArch string
-
-func detectArch() string {
-	switch runtime.GOOS {
-	case "darwin":
-		return detectDarwinArch()
-	case "linux":
-		if !hasDeterminedArch {
-			cachedArch = detectLinuxArch()
-			hasDeterminedArch = true
-		}
-		return cachedArch
-	case "windows":
-		return detectWindowsArch()
-	}
-
-	// unsupported platform - fall back to compile-time arch
-	return runtime.GOARCH
-}
-
-func detectDarwinArch() string {
-	// Check for Apple Silicon - sysctl returns "1" on ARM Macs even under Rosetta
-	output, err := exec.Command("sysctl", "-n", "hw.optional.arm64").Output()
-	if err == nil && strings.TrimSpace(string(output)) == "1" {
-		return "arm64"
-	}
-	// Fall back to uname for older Intel Macs
-	output, err = exec.Command("uname", "-m").Output()
-	if err == nil {
-		machine := strings.TrimSpace(string(output))
-		if arch, ok := archMapping[machine]; ok {
-			return arch
-		}
-	}
-	return runtime.GOARCH
-}
-
-func detectLinuxArch() string {
-	output, err := exec.Command("uname", "-m").Output()
-	if err == nil {
-		machine := strings.TrimSpace(string(output))
-		if arch, ok := archMapping[machine]; ok {
-			return arch
-		}
-	}
-
-	output, err = exec.Command("arch").Output()
-	if err == nil {
-		machine := strings.TrimSpace(string(output))
-		if arch, ok := archMapping[machine]; ok {
-			return arch
-		}
-	}
-
-	// Fall back to compile-time arch
-	return runtime.GOARCH
-}
-
-func detectWindowsArch() string {
-	// If we're running as a 64-bit executable, check what kind
-	if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
-		return runtime.GOARCH
-	}
-
-	// 32-bit binary running on potentially 64-bit OS - check env vars
-	// PROCESSOR_ARCHITEW6432 is set when a 32-bit process runs on 64-bit Windows
-	if archEnv := os.Getenv("PROCESSOR_ARCHITEW6432"); archEnv != "" {
-		if arch, ok := archMapping[archEnv]; ok {
-			return arch
-		}
-	}
-
-	if archEnv := os.Getenv("PROCESSOR_ARCHITECTURE"); archEnv != "" {
-		if arch, ok := archMapping[archEnv]; ok {
-			return arch
-		}
-	}
-
-	// Fall back to compile-time arch
-	return runtime.GOARCH
-}
@@ -0,0 +1,131 @@
+package ox
+
+import "fmt"
+
+// Compatibility describes how (and whether) a host runtime
+// is able to run executables built for a target runtime.
+type Compatibility int
+
+const (
+	// CompatibilityNone means the target cannot run on the host
+	CompatibilityNone Compatibility = iota
+	// CompatibilityNative means the target matches the host exactly
+	CompatibilityNative
+	// CompatibilityPlatform means the platform matches, but the target
+	// doesn't specify an architecture, so we can't tell for sure
+	CompatibilityPlatform
+	// CompatibilityEmulated means the target runs through an emulation
+	// layer, like Rosetta 2 or Windows on ARM's x86 emulation
+	CompatibilityEmulated
+	// CompatibilityMultilib means a 32-bit target runs on a 64-bit host,
+	// as long as the relevant 32-bit libraries are installed
+	CompatibilityMultilib
+)
+
+func (c Compatibility) String() string {
+	switch c {
+	case CompatibilityNative:
+		return "native"
+	case CompatibilityPlatform:
+		return "platform"
+	case CompatibilityEmulated:
+		return "emulated"
+	case CompatibilityMultilib:
+		return "multilib"
+	default:
+		return "none"
+	}
+}
+
+// CompatibilityResult is returned by Runtime.Compatibility
+type CompatibilityResult struct {
+	Compatibility Compatibility
+	// Reason is a human-readable explanation of the result
+	Reason string
+}
+
+// OK returns true if the target can run on the host
+func (cr CompatibilityResult) OK() bool {
+	return cr.Compatibility != CompatibilityNone
+}
+
+type compatibilityRule struct {
+	platform      Platform
+	host          string
+	target        string
+	compatibility Compatibility
+	reason        string
+}
+
+// compatibilityRules lists the cases where a host can run
+// a target built for a different architecture. Anything that
+// isn't listed here, and isn't an exact match, can't run.
+var compatibilityRules = []compatibilityRule{
+	// 32-bit x86 on 64-bit x86
+	{PlatformLinux, "amd64", "386", CompatibilityMultilib, "32-bit x86 builds run on 64-bit x86 Linux if i386 libraries are installed"},
+	{PlatformWindows, "amd64", "386", CompatibilityMultilib, "32-bit x86 builds run on 64-bit x86 Windows through WOW64"},
+	// note: macOS dropped support for 32-bit apps in 10.15 Catalina
+
+	// 32-bit ARM on 64-bit ARM
+	{PlatformLinux, "arm64", "arm", CompatibilityMultilib, "32-bit ARM builds run on 64-bit ARM Linux if the kernel supports AArch32 and an armhf userland is installed"},
+
+	// emulation layers
+	{PlatformOSX, "arm64", "amd64", CompatibilityEmulated, "x86-64 builds run on Apple Silicon through Rosetta 2"},
+	{PlatformWindows, "arm64", "386", CompatibilityEmulated, "32-bit x86 builds run on Windows on ARM through emulation"},
+	{PlatformWindows, "arm64", "amd64", CompatibilityEmulated, "x86-64 builds run on Windows 11 on ARM through emulation"},
+}
+
+// Compatibility returns whether, and how, r can run
+// executables built for target.
+// If target doesn't specify an Architecture, only
+// platforms and word sizes (Is64) are compared.
+func (r Runtime) Compatibility(target Runtime) CompatibilityResult {
+	if r.Platform != target.Platform {
+		return CompatibilityResult{
+			Compatibility: CompatibilityNone,
+			Reason:        fmt.Sprintf("%s builds don't run on %s", target.platformName(), r.platformName()),
+		}
+	}
+
+	if target.Architecture == "" {
+		if target.Is64 && !r.Is64 && !isArch64(r.Arch()) {
+			return CompatibilityResult{
+				Compatibility: CompatibilityNone,
+				Reason:        fmt.Sprintf("64-bit %s builds don't run on %s %s", target.platformName(), r.Arch(), r.platformName()),
+			}
+		}
+		return CompatibilityResult{
+			Compatibility: CompatibilityPlatform,
+			Reason:        fmt.Sprintf("%s build with unspecified architecture", target.platformName()),
+		}
+	}
+
+	hostArch := r.Arch()
+	targetArch := target.Arch()
+	if hostArch == targetArch {
+		return CompatibilityResult{
+			Compatibility: CompatibilityNative,
+			Reason:        fmt.Sprintf("%s builds run natively", targetArch),
+		}
+	}
+
+	for _, rule := range compatibilityRules {
+		if rule.platform == r.Platform && rule.host == hostArch && rule.target == targetArch {
+			return CompatibilityResult{
+				Compatibility: rule.compatibility,
+				Reason:        rule.reason,
+			}
+		}
+	}
+
+	return CompatibilityResult{
+		Compatibility: CompatibilityNone,
+		Reason:        fmt.Sprintf("%s builds don't run on %s %s", targetArch, hostArch, r.platformName()),
+	}
+}
+
+// CanRun returns true if r can run executables built for target,
+// natively or not. See Compatibility for details.
+func (r Runtime) CanRun(target Runtime) bool {
+	return r.Compatibility(target).OK()
+}
@@ -0,0 +1,57 @@
+package ox_test
+
+import (
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntime_Compatibility(t *testing.T) {
+	linux := func(arch string) ox.Runtime {
+		return ox.Runtime{Platform: ox.PlatformLinux, Is64: arch == "amd64" || arch == "arm64", Architecture: arch}
+	}
+	windows := func(arch string) ox.Runtime {
+		return ox.Runtime{Platform: ox.PlatformWindows, Is64: arch == "amd64" || arch == "arm64", Architecture: arch}
+	}
+	osx := func(arch string) ox.Runtime {
+		return ox.Runtime{Platform: ox.PlatformOSX, Is64: arch == "amd64" || arch == "arm64", Architecture: arch}
+	}
+
+	tests := []struct {
+		name     string
+		host     ox.Runtime
+		target   ox.Runtime
+		expected ox.Compatibility
+	}{
+		{"linux amd64 native", linux("amd64"), linux("amd64"), ox.CompatibilityNative},
+		{"linux amd64 runs 386", linux("amd64"), linux("386"), ox.CompatibilityMultilib},
+		{"linux 386 can't run amd64", linux("386"), linux("amd64"), ox.CompatibilityNone},
+		{"linux arm64 runs arm", linux("arm64"), linux("arm"), ox.CompatibilityMultilib},
+		{"linux arm64 can't run amd64", linux("arm64"), linux("amd64"), ox.CompatibilityNone},
+		{"linux amd64 can't run arm64", linux("amd64"), linux("arm64"), ox.CompatibilityNone},
+		{"linux can't run windows", linux("amd64"), windows("amd64"), ox.CompatibilityNone},
+		{"windows amd64 runs 386", windows("amd64"), windows("386"), ox.CompatibilityMultilib},
+		{"windows arm64 runs amd64", windows("arm64"), windows("amd64"), ox.CompatibilityEmulated},
+		{"windows arm64 runs 386", windows("arm64"), windows("386"), ox.CompatibilityEmulated},
+		{"windows arm64 native", windows("arm64"), windows("arm64"), ox.CompatibilityNative},
+		{"osx arm64 runs amd64", osx("arm64"), osx("amd64"), ox.CompatibilityEmulated},
+		{"osx amd64 can't run arm64", osx("amd64"), osx("arm64"), ox.CompatibilityNone},
+		{"osx amd64 can't run 386", osx("amd64"), osx("386"), ox.CompatibilityNone},
+		{"unspecified target arch", linux("arm64"), ox.Runtime{Platform: ox.PlatformLinux}, ox.CompatibilityPlatform},
+		{"unspecified 64-bit target arch", linux("amd64"), ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, ox.CompatibilityPlatform},
+		{"32-bit host can't run unspecified 64-bit", linux("386"), ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, ox.CompatibilityNone},
+		{"32-bit arm host can't run unspecified 64-bit", linux("arm"), ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, ox.CompatibilityNone},
+		{"legacy 32-bit host can't run unspecified 64-bit", ox.Runtime{Platform: ox.PlatformLinux}, ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, ox.CompatibilityNone},
+		{"legacy host without arch", ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, linux("386"), ox.CompatibilityMultilib},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.host.Compatibility(tc.target)
+			assert.Equal(t, tc.expected, res.Compatibility, "reason: %s", res.Reason)
+			assert.NotEmpty(t, res.Reason)
+			assert.Equal(t, tc.expected != ox.CompatibilityNone, tc.host.CanRun(tc.target))
+		})
+	}
+}
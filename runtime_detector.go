@@ -0,0 +1,373 @@
+package ox
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ArchSource identifies where an architecture was detected from
+type ArchSource string
+
+const (
+	ArchSourceUname   ArchSource = "uname"
+	ArchSourceAuxv    ArchSource = "auxv"
+	ArchSourceCPUInfo ArchSource = "cpuinfo"
+	ArchSourceSysctl  ArchSource = "sysctl"
+	ArchSourceEnv     ArchSource = "env"
+	ArchSourceGOARCH  ArchSource = "goarch"
+)
+
+// ArchDetection is the result of architecture detection
+type ArchDetection struct {
+	// Arch is the detected architecture, in GOARCH format
+	Arch string
+	// Source is the source Arch was taken from
+	Source ArchSource
+	// Observed holds the architecture reported by each source
+	// that was consulted, for cross-checking
+	Observed map[ArchSource]string
+}
+
+// Conflicting returns true if sources disagreed on the architecture
+func (ad ArchDetection) Conflicting() bool {
+	for _, arch := range ad.Observed {
+		if arch != ad.Arch {
+			return true
+		}
+	}
+	return false
+}
+
+// RuntimeDetector determines the Runtime of a host from
+// a set of sources. Sources can be swapped out, so that detection
+// for every platform can be tested from any platform.
+// A nil source is treated as unavailable.
+type RuntimeDetector struct {
+	// GOOS and GOARCH are those of the running binary
+	GOOS   string
+	GOARCH string
+
+	// Uname returns the machine hardware name, as printed by `uname -m`
+	Uname func() (string, error)
+	// AuxvPlatform returns the AT_PLATFORM entry of the auxiliary vector,
+	// like "x86_64" or "v7l" (Linux only)
+	AuxvPlatform func() (string, error)
+	// CPUInfo returns the contents of /proc/cpuinfo (Linux only)
+	CPUInfo func() ([]byte, error)
+	// Sysctl returns the value of a sysctl, as printed by `sysctl -n`
+	Sysctl func(name string) (string, error)
+	// Getenv returns the value of an environment variable
+	Getenv func(key string) string
+	// ExecutableRuntime returns the Runtime an executable
+	// targets, see RuntimeOfFile
+	ExecutableRuntime func(path string) (Runtime, error)
+	// Interpreter returns the ELF interpreter of an executable,
+	// see ELFInterpreter
+	Interpreter func(path string) (string, error)
+	// Root is where UserlandExecutables, and their interpreters,
+	// are looked up. Defaults to "/" (Linux only)
+	Root string
+	// UserlandExecutables are system executables and loaders, inspected
+	// in order by DetectUserlandArch: the first one that can be read
+	// wins. Entries may be glob patterns (Linux only)
+	UserlandExecutables []string
+	// Executable is the running binary, which DetectUserlandArch only
+	// looks at if none of UserlandExecutables can be read. It's not
+	// looked up in Root (Linux only)
+	Executable string
+}
+
+// DefaultUserlandExecutables are system executables and loaders whose
+// architecture matches that of the userland. Executables are checked
+// first, since they tell which loader the system actually uses: a
+// multiarch system may have loaders for several architectures.
+var DefaultUserlandExecutables = []string{
+	"/bin/sh",
+	"/usr/bin/env",
+	"/lib64/ld-linux-x86-64.so.2",
+	"/lib/ld-linux-aarch64.so.1",
+	"/lib/ld-linux-armhf.so.3",
+	"/lib/ld-linux*.so*",
+	"/lib/*/ld-linux*.so*",
+}
+
+// NewRuntimeDetector returns a RuntimeDetector that
+// inspects the machine we're running on.
+func NewRuntimeDetector() *RuntimeDetector {
+	return &RuntimeDetector{
+		GOOS:         runtime.GOOS,
+		GOARCH:       runtime.GOARCH,
+		Uname:        hostUname,
+		AuxvPlatform: hostAuxvPlatform,
+		CPUInfo:      hostCPUInfo,
+		Sysctl: func(name string) (string, error) {
+			return commandSource("sysctl", "-n", name)()
+		},
+		Getenv:              os.Getenv,
+		ExecutableRuntime:   RuntimeOfFile,
+		Interpreter:         ELFInterpreter,
+		UserlandExecutables: DefaultUserlandExecutables,
+		Executable:          "/proc/self/exe",
+	}
+}
+
+func commandSource(name string, args ...string) func() (string, error) {
+	return func() (string, error) {
+		output, err := exec.Command(name, args...).Output()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+}
+
+// Detect returns the Runtime of the host
+func (d *RuntimeDetector) Detect() Runtime {
+	return d.runtimeForArch(d.DetectArch())
+}
+
+func (d *RuntimeDetector) runtimeForArch(arch string) Runtime {
+	r := Runtime{
+		Platform: platformFromGOOS(d.GOOS),
+	}
+
+	if d.GOOS == "linux" {
+		// a 64-bit kernel may be running a 32-bit userland (armhf on
+		// a Raspberry Pi, for example), in which case 64-bit builds won't run.
+		r.KernelArchitecture = arch
+		var fromExecutable bool
+		r.UserlandArchitecture, fromExecutable = d.detectUserlandArch()
+		// our own binary may be a 32-bit (or static 64-bit) build that
+		// doesn't match the rest of the system, so it never wins
+		if r.UserlandArchitecture != "" && !fromExecutable {
+			kernel := Runtime{Platform: r.Platform, Architecture: arch}
+			userland := Runtime{Platform: r.Platform, Architecture: r.UserlandArchitecture}
+			if kernel.CanRun(userland) {
+				arch = r.UserlandArchitecture
+			}
+		}
+	}
+
+	r.Architecture = arch
+	r.Is64 = isArch64(arch)
+	return r
+}
+
+// DetectUserlandArch returns the architecture of the userland, in GOARCH
+// format, by looking at the ELF headers of the loader of each of
+// UserlandExecutables, or of the executable itself if it has none,
+// and then of Executable. Returns an empty string if none of them
+// could be read.
+func (d *RuntimeDetector) DetectUserlandArch() string {
+	arch, _ := d.detectUserlandArch()
+	return arch
+}
+
+// detectUserlandArch is DetectUserlandArch, but also returns true
+// if the architecture only comes from Executable
+func (d *RuntimeDetector) detectUserlandArch() (string, bool) {
+	if d.ExecutableRuntime == nil {
+		return "", false
+	}
+
+	root := d.Root
+	if root == "" {
+		root = "/"
+	}
+
+	for _, pattern := range d.UserlandExecutables {
+		paths := []string{filepath.Join(root, pattern)}
+		if strings.ContainsAny(pattern, "*?[") {
+			paths, _ = filepath.Glob(paths[0])
+		}
+		for _, path := range paths {
+			if arch := d.executableArch(root, path); arch != "" {
+				return arch, false
+			}
+		}
+	}
+
+	if d.Executable != "" {
+		if arch := d.executableArch("/", d.Executable); arch != "" {
+			return arch, true
+		}
+	}
+	return "", false
+}
+
+// executableArch returns the architecture of the interpreter of
+// path, looked up in root, or else of path itself
+func (d *RuntimeDetector) executableArch(root string, path string) string {
+	if d.Interpreter != nil {
+		if interp, err := d.Interpreter(path); err == nil && interp != "" {
+			r, err := d.ExecutableRuntime(filepath.Join(root, interp))
+			if err == nil && r.Architecture != "" {
+				return r.Architecture
+			}
+		}
+	}
+
+	r, err := d.ExecutableRuntime(path)
+	if err == nil && r.Architecture != "" {
+		return r.Architecture
+	}
+	return ""
+}
+
+// DetectArch returns the architecture of the host, in GOARCH format
+func (d *RuntimeDetector) DetectArch() string {
+	return d.DetectArchWithSource().Arch
+}
+
+// DetectArchWithSource returns the architecture of the host,
+// along with which source it was detected from.
+func (d *RuntimeDetector) DetectArchWithSource() ArchDetection {
+	ad := ArchDetection{
+		Observed: make(map[ArchSource]string),
+	}
+
+	switch d.GOOS {
+	case "darwin":
+		d.detectDarwinArch(&ad)
+	case "linux":
+		d.detectLinuxArch(&ad)
+	case "windows":
+		d.detectWindowsArch(&ad)
+	}
+
+	if ad.Source == "" {
+		// unsupported platform or no usable source - fall back to compile-time arch
+		ad.Arch = d.GOARCH
+		ad.Source = ArchSourceGOARCH
+	}
+	return ad
+}
+
+// observe records what a source reported, and picks it
+// if no other source has been picked yet.
+func (ad *ArchDetection) observe(source ArchSource, arch string) {
+	if arch == "" {
+		return
+	}
+	ad.Observed[source] = arch
+	if ad.Source == "" {
+		ad.Arch = arch
+		ad.Source = source
+	}
+}
+
+// mapSource calls source and maps its output with archMapping.
+// Returns an empty string if the source is unavailable, fails, or returns
+// an unknown architecture.
+func mapSource(source func() (string, error)) string {
+	if source == nil {
+		return ""
+	}
+	output, err := source()
+	if err != nil {
+		return ""
+	}
+	return archMapping[strings.TrimSpace(output)]
+}
+
+func (d *RuntimeDetector) detectDarwinArch(ad *ArchDetection) {
+	// Check for Apple Silicon - sysctl returns "1" on ARM Macs even under Rosetta
+	if d.Sysctl != nil {
+		output, err := d.Sysctl("hw.optional.arm64")
+		if err == nil && strings.TrimSpace(output) == "1" {
+			ad.observe(ArchSourceSysctl, "arm64")
+		}
+	}
+	// Fall back to uname for older Intel Macs
+	ad.observe(ArchSourceUname, mapSource(d.Uname))
+}
+
+func (d *RuntimeDetector) detectLinuxArch(ad *ArchDetection) {
+	ad.observe(ArchSourceUname, mapSource(d.Uname))
+	ad.observe(ArchSourceAuxv, mapSource(d.auxvMachine))
+	if d.CPUInfo != nil {
+		if cpuinfo, err := d.CPUInfo(); err == nil {
+			ad.observe(ArchSourceCPUInfo, archFromCPUInfo(cpuinfo))
+		}
+	}
+}
+
+// auxvMachine returns AT_PLATFORM in `uname -m` format.
+// On 32-bit ARM, AT_PLATFORM omits the "arm" prefix, e.g. "v7l"
+func (d *RuntimeDetector) auxvMachine() (string, error) {
+	if d.AuxvPlatform == nil {
+		return "", os.ErrNotExist
+	}
+	platform, err := d.AuxvPlatform()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(platform, "v") && strings.HasSuffix(platform, "l") {
+		platform = "arm" + platform
+	}
+	return platform, nil
+}
+
+// archFromCPUInfo guesses the architecture from the contents of /proc/cpuinfo.
+// Note that this describes what the CPU is capable of, which is why it
+// is only used if the kernel can't tell us.
+func archFromCPUInfo(cpuinfo []byte) string {
+	isX86 := false
+	scanner := bufio.NewScanner(bytes.NewReader(cpuinfo))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "vendor_id":
+			isX86 = true
+		case "flags":
+			// "lm" (long mode) is only set on x86-64 capable CPUs
+			for _, flag := range strings.Fields(value) {
+				if flag == "lm" {
+					return "amd64"
+				}
+			}
+			if isX86 {
+				return "386"
+			}
+		case "CPU architecture":
+			if value == "8" || value == "AArch64" {
+				return "arm64"
+			}
+			return "arm"
+		case "Processor":
+			// older ARM kernels
+			if strings.Contains(value, "AArch64") {
+				return "arm64"
+			}
+		}
+	}
+	return ""
+}
+
+func (d *RuntimeDetector) detectWindowsArch(ad *ArchDetection) {
+	// If we're running as a 64-bit executable, check what kind
+	if d.GOARCH == "amd64" || d.GOARCH == "arm64" {
+		return
+	}
+
+	if d.Getenv != nil {
+		// 32-bit binary running on potentially 64-bit OS - check env vars
+		// PROCESSOR_ARCHITEW6432 is set when a 32-bit process runs on 64-bit Windows
+		arch := archMapping[d.Getenv("PROCESSOR_ARCHITEW6432")]
+		if arch == "" {
+			arch = archMapping[d.Getenv("PROCESSOR_ARCHITECTURE")]
+		}
+		ad.observe(ArchSourceEnv, arch)
+	}
+}
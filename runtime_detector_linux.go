@@ -0,0 +1,80 @@
+package ox
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// AT_PLATFORM, cf. getauxval(3)
+const atPlatform = 15
+
+func hostUname() (string, error) {
+	var uts unix.Utsname
+	err := unix.Uname(&uts)
+	if err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(uts.Machine[:]), nil
+}
+
+// maxAuxvPlatformLength bounds how much of AT_PLATFORM is read,
+// actual values are short, like "x86_64" or "aarch64"
+const maxAuxvPlatformLength = 64
+
+// hostAuxvPlatform reads the AT_PLATFORM string the kernel passed
+// us. The auxiliary vector only has its address, so it's read back
+// through /proc/self/mem, which sandboxes (seccomp, gVisor, yama)
+// may deny, in which case the source is simply unavailable.
+func hostAuxvPlatform() (string, error) {
+	auxv, err := unix.Auxv()
+	if err != nil {
+		return "", err
+	}
+
+	var addr uintptr
+	for _, entry := range auxv {
+		if entry[0] == atPlatform {
+			addr = entry[1]
+			break
+		}
+	}
+	if addr == 0 {
+		return "", os.ErrNotExist
+	}
+
+	mem, err := os.Open("/proc/self/mem")
+	if err != nil {
+		return "", err
+	}
+	defer mem.Close()
+
+	// read a page at most at a time, so a short string at the
+	// end of a mapping doesn't make the read fail
+	pageSize := uintptr(os.Getpagesize())
+	var platform []byte
+	for len(platform) < maxAuxvPlatformLength {
+		offset := addr + uintptr(len(platform))
+		n := pageSize - offset%pageSize
+		if remaining := uintptr(maxAuxvPlatformLength - len(platform)); n > remaining {
+			n = remaining
+		}
+
+		buf := make([]byte, n)
+		read, err := mem.ReadAt(buf, int64(offset))
+		if read == 0 {
+			return "", err
+		}
+		if i := bytes.IndexByte(buf[:read], 0); i >= 0 {
+			return string(append(platform, buf[:i]...)), nil
+		}
+		platform = append(platform, buf[:read]...)
+	}
+	return "", errors.Errorf("AT_PLATFORM longer than %d bytes", maxAuxvPlatformLength)
+}
+
+func hostCPUInfo() ([]byte, error) {
+	return os.ReadFile("/proc/cpuinfo")
+}
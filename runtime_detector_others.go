@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ox
+
+var hostUname = commandSource("uname", "-m")
+
+// only available on Linux
+var hostAuxvPlatform func() (string, error)
+var hostCPUInfo func() ([]byte, error)
@@ -0,0 +1,343 @@
+package ox_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixedSource(output string) func() (string, error) {
+	return func() (string, error) {
+		return output, nil
+	}
+}
+
+func failingSource() (string, error) {
+	return "", errors.New("not available")
+}
+
+func fixedEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestRuntimeDetector_Linux(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector ox.RuntimeDetector
+		expected ox.Runtime
+		source   ox.ArchSource
+	}{
+		{
+			name:     "uname",
+			detector: ox.RuntimeDetector{GOOS: "linux", GOARCH: "amd64", Uname: fixedSource("aarch64\n")},
+			expected: ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64", KernelArchitecture: "arm64"},
+			source:   ox.ArchSourceUname,
+		},
+		{
+			name:     "auxv fallback",
+			detector: ox.RuntimeDetector{GOOS: "linux", GOARCH: "amd64", Uname: failingSource, AuxvPlatform: fixedSource("v7l")},
+			expected: ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "arm", KernelArchitecture: "arm"},
+			source:   ox.ArchSourceAuxv,
+		},
+		{
+			name:     "cpuinfo fallback",
+			detector: ox.RuntimeDetector{GOOS: "linux", GOARCH: "386", Uname: failingSource, CPUInfo: fixedCPUInfo(x86CPUInfo)},
+			expected: ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64", KernelArchitecture: "amd64"},
+			source:   ox.ArchSourceCPUInfo,
+		},
+		{
+			name:     "GOARCH fallback",
+			detector: ox.RuntimeDetector{GOOS: "linux", GOARCH: "arm", Uname: fixedSource("mystery"), AuxvPlatform: failingSource},
+			expected: ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "arm", KernelArchitecture: "arm"},
+			source:   ox.ArchSourceGOARCH,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.detector.Detect())
+			assert.Equal(t, tc.source, tc.detector.DetectArchWithSource().Source)
+		})
+	}
+}
+
+const x86CPUInfo = `processor	: 0
+vendor_id	: GenuineIntel
+model name	: Intel(R) Core(TM) i5-3570K CPU @ 3.40GHz
+flags		: fpu vme de pse tsc msr pae mce cx8 apic sep mtrr lm sse4_2
+`
+
+const armCPUInfo = `processor	: 0
+model name	: ARMv7 Processor rev 4 (v7l)
+Features	: half thumb fastmult vfp edsp neon vfpv3 tls vfpv4 idiva idivt vfpd32 lpae evtstrm crc32
+CPU architecture: 7
+`
+
+const arm64CPUInfo = `processor	: 0
+BogoMIPS	: 108.00
+Features	: fp asimd evtstrm crc32 cpuid
+CPU implementer	: 0x41
+CPU architecture: 8
+`
+
+func fixedCPUInfo(contents string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return []byte(contents), nil
+	}
+}
+
+func TestRuntimeDetector_CrossCheck(t *testing.T) {
+	// 64-bit kernel, but we're a 32-bit process
+	d := ox.RuntimeDetector{
+		GOOS:         "linux",
+		GOARCH:       "arm",
+		Uname:        fixedSource("aarch64"),
+		AuxvPlatform: fixedSource("v8l"),
+		CPUInfo:      fixedCPUInfo(arm64CPUInfo),
+	}
+	ad := d.DetectArchWithSource()
+	assert.Equal(t, "arm64", ad.Arch)
+	assert.Equal(t, ox.ArchSourceUname, ad.Source)
+	assert.Equal(t, map[ox.ArchSource]string{
+		ox.ArchSourceUname:   "arm64",
+		ox.ArchSourceAuxv:    "arm",
+		ox.ArchSourceCPUInfo: "arm64",
+	}, ad.Observed)
+	assert.True(t, ad.Conflicting())
+
+	d = ox.RuntimeDetector{
+		GOOS:         "linux",
+		GOARCH:       "arm",
+		Uname:        fixedSource("armv7l"),
+		AuxvPlatform: fixedSource("v7l"),
+		CPUInfo:      fixedCPUInfo(armCPUInfo),
+	}
+	ad = d.DetectArchWithSource()
+	assert.Equal(t, "arm", ad.Arch)
+	assert.False(t, ad.Conflicting())
+}
+
+func TestRuntimeDetector_Userland(t *testing.T) {
+	executables := func(archs map[string]string) func(string) (ox.Runtime, error) {
+		return func(path string) (ox.Runtime, error) {
+			if arch, ok := archs[path]; ok {
+				return ox.Runtime{Platform: ox.PlatformLinux, Architecture: arch}, nil
+			}
+			return ox.Runtime{}, errors.New("no such file")
+		}
+	}
+
+	interpreters := func(interps map[string]string) func(string) (string, error) {
+		return func(path string) (string, error) {
+			if interp, ok := interps[path]; ok {
+				return interp, nil
+			}
+			return "", errors.New("no such file")
+		}
+	}
+
+	// Raspberry Pi OS: 64-bit kernel, armhf userland, even
+	// if we're a static arm64 build
+	d := ox.RuntimeDetector{
+		GOOS:                "linux",
+		GOARCH:              "arm64",
+		Uname:               fixedSource("aarch64"),
+		ExecutableRuntime:   executables(map[string]string{"/lib/ld-linux-armhf.so.3": "arm", "/proc/self/exe": "arm64"}),
+		Interpreter:         interpreters(map[string]string{"/bin/sh": "/lib/ld-linux-armhf.so.3", "/proc/self/exe": ""}),
+		UserlandExecutables: []string{"/bin/sh", "/usr/bin/env"},
+		Executable:          "/proc/self/exe",
+	}
+	assert.Equal(t, ox.Runtime{
+		Platform:             ox.PlatformLinux,
+		Is64:                 false,
+		Architecture:         "arm",
+		KernelArchitecture:   "arm64",
+		UserlandArchitecture: "arm",
+	}, d.Detect())
+
+	// the loader can't be read, fall back to the executable itself
+	d.ExecutableRuntime = executables(map[string]string{"/usr/bin/env": "arm"})
+	assert.Equal(t, "arm", d.DetectUserlandArch())
+	assert.Equal(t, "arm", d.Detect().Architecture)
+
+	// nothing can be read, kernel arch it is
+	d.ExecutableRuntime = executables(nil)
+	r := d.Detect()
+	assert.Equal(t, "arm64", r.Architecture)
+	assert.Equal(t, "", r.UserlandArchitecture)
+
+	// our own binary is only a last resort, and never
+	// downgrades the kernel arch
+	d.Uname = fixedSource("x86_64")
+	d.GOARCH = "386"
+	d.ExecutableRuntime = executables(map[string]string{"/proc/self/exe": "386"})
+	r = d.Detect()
+	assert.Equal(t, "386", r.UserlandArchitecture)
+	assert.Equal(t, "amd64", r.Architecture)
+	assert.True(t, r.Is64)
+
+	// userlands the kernel can't run are ignored (qemu-user, etc.)
+	d.ExecutableRuntime = executables(map[string]string{"/bin/sh": "arm64"})
+	r = d.Detect()
+	assert.Equal(t, "amd64", r.Architecture)
+	assert.Equal(t, "arm64", r.UserlandArchitecture)
+}
+
+// writeELF writes a minimal little-endian ELF executable for
+// machine, with a PT_INTERP program header if interp isn't empty
+func writeELF(t *testing.T, path string, class elf.Class, machine elf.Machine, interp string) {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	ident := [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+
+	var phnum uint16
+	if interp != "" {
+		phnum = 1
+	}
+	interpData := append([]byte(interp), 0)
+
+	if class == elf.ELFCLASS64 {
+		ehsize, phsize := uint16(64), uint16(56)
+		must(binary.Write(&buf, le, elf.Header64{
+			Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT),
+			Phoff: uint64(ehsize), Ehsize: ehsize, Phentsize: phsize, Phnum: phnum,
+		}))
+		if interp != "" {
+			off := uint64(ehsize + phsize)
+			size := uint64(len(interpData))
+			must(binary.Write(&buf, le, elf.Prog64{Type: uint32(elf.PT_INTERP), Off: off, Filesz: size, Memsz: size}))
+		}
+	} else {
+		ehsize, phsize := uint16(52), uint16(32)
+		must(binary.Write(&buf, le, elf.Header32{
+			Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT),
+			Phoff: uint32(ehsize), Ehsize: ehsize, Phentsize: phsize, Phnum: phnum,
+		}))
+		if interp != "" {
+			off := uint32(ehsize + phsize)
+			size := uint32(len(interpData))
+			must(binary.Write(&buf, le, elf.Prog32{Type: uint32(elf.PT_INTERP), Off: off, Filesz: size, Memsz: size}))
+		}
+	}
+	if interp != "" {
+		buf.Write(interpData)
+	}
+
+	must(os.MkdirAll(filepath.Dir(path), 0o755))
+	must(os.WriteFile(path, buf.Bytes(), 0o755))
+}
+
+func TestCurrentArchDetection(t *testing.T) {
+	// the real thing: sources may legitimately disagree (32-bit
+	// containers, qemu-user, etc.), so only check it's one of them
+	ad := ox.CurrentArchDetection()
+	assert.NotEmpty(t, ad.Arch)
+	if ad.Source != ox.ArchSourceGOARCH {
+		assert.Equal(t, ad.Arch, ad.Observed[ad.Source])
+	}
+
+	// the same detector, with system files from a fake root
+	detect := func(uname string, root string, executable string) ox.Runtime {
+		d := ox.NewRuntimeDetector()
+		d.GOOS = "linux"
+		d.Uname = fixedSource(uname)
+		d.AuxvPlatform = nil
+		d.CPUInfo = nil
+		d.Root = root
+		d.Executable = executable
+		return d.Detect()
+	}
+
+	dir := t.TempDir()
+	static64 := filepath.Join(dir, "static-arm64")
+	writeELF(t, static64, elf.ELFCLASS64, elf.EM_AARCH64, "")
+	static386 := filepath.Join(dir, "static-386")
+	writeELF(t, static386, elf.ELFCLASS32, elf.EM_386, "")
+
+	// Raspberry Pi OS, running a static arm64 build
+	pi := filepath.Join(dir, "pi")
+	writeELF(t, filepath.Join(pi, "bin/sh"), elf.ELFCLASS32, elf.EM_ARM, "/lib/ld-linux-armhf.so.3")
+	writeELF(t, filepath.Join(pi, "lib/ld-linux-armhf.so.3"), elf.ELFCLASS32, elf.EM_ARM, "")
+	r := detect("aarch64", pi, static64)
+	assert.Equal(t, "arm", r.Architecture)
+	assert.False(t, r.Is64)
+	assert.Equal(t, "arm64", r.KernelArchitecture)
+	assert.Equal(t, "arm", r.UserlandArchitecture)
+
+	// a 386 build on an x86_64 distribution without /bin/sh
+	distroless := filepath.Join(dir, "distroless")
+	writeELF(t, filepath.Join(distroless, "lib64/ld-linux-x86-64.so.2"), elf.ELFCLASS64, elf.EM_X86_64, "")
+	r = detect("x86_64", distroless, static386)
+	assert.Equal(t, "amd64", r.Architecture)
+	assert.True(t, r.Is64)
+	assert.Equal(t, "amd64", r.UserlandArchitecture)
+
+	// nothing but our own 386 binary: it doesn't downgrade the kernel arch
+	r = detect("x86_64", filepath.Join(dir, "empty"), static386)
+	assert.Equal(t, "amd64", r.Architecture)
+	assert.True(t, r.Is64)
+	assert.Equal(t, "386", r.UserlandArchitecture)
+}
+
+func TestRuntimeDetector_Darwin(t *testing.T) {
+	sysctl := func(value string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			assert.Equal(t, "hw.optional.arm64", name)
+			return value, nil
+		}
+	}
+
+	// Apple Silicon, even when running under Rosetta
+	d := ox.RuntimeDetector{GOOS: "darwin", GOARCH: "amd64", Sysctl: sysctl("1\n"), Uname: fixedSource("x86_64")}
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "arm64"}, d.Detect())
+
+	// Intel Mac
+	d = ox.RuntimeDetector{GOOS: "darwin", GOARCH: "amd64", Sysctl: sysctl("0"), Uname: fixedSource("x86_64")}
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "amd64"}, d.Detect())
+}
+
+func TestRuntimeDetector_Windows(t *testing.T) {
+	// 64-bit binaries know what they're running on
+	d := ox.RuntimeDetector{GOOS: "windows", GOARCH: "arm64"}
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "arm64"}, d.Detect())
+
+	// 32-bit binary on 64-bit Windows
+	d = ox.RuntimeDetector{GOOS: "windows", GOARCH: "386", Getenv: fixedEnv(map[string]string{
+		"PROCESSOR_ARCHITECTURE": "x86",
+		"PROCESSOR_ARCHITEW6432": "AMD64",
+	})}
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "amd64"}, d.Detect())
+
+	// 32-bit binary on 32-bit Windows
+	d = ox.RuntimeDetector{GOOS: "windows", GOARCH: "386", Getenv: fixedEnv(map[string]string{
+		"PROCESSOR_ARCHITECTURE": "x86",
+	})}
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformWindows, Is64: false, Architecture: "386"}, d.Detect())
+}
+
+func TestCurrentRuntime_Concurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	results := make([]ox.Runtime, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = ox.CurrentRuntime()
+		}(i)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		assert.Equal(t, results[0], r)
+	}
+}
@@ -0,0 +1,278 @@
+package ox
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ScriptError is returned by RuntimeOfFile when the file
+// is a script (starts with a shebang) rather than a native executable.
+type ScriptError struct {
+	Path        string
+	Interpreter string
+}
+
+func (se *ScriptError) Error() string {
+	return fmt.Sprintf("%s is a script (interpreter: %s), not a native executable", se.Path, se.Interpreter)
+}
+
+// UnknownFormatError is returned by RuntimeOfFile when the file
+// is neither an ELF, PE nor Mach-O executable.
+type UnknownFormatError struct {
+	Path string
+}
+
+func (ufe *UnknownFormatError) Error() string {
+	return fmt.Sprintf("%s: unknown executable format", ufe.Path)
+}
+
+// RuntimeOfFile returns the Runtime an executable file targets,
+// by reading its ELF, PE or Mach-O headers.
+// For universal (fat) Mach-O binaries, the first 64-bit slice
+// is returned, see RuntimesOfFile to get all of them.
+func RuntimeOfFile(path string) (Runtime, error) {
+	rs, err := RuntimesOfFile(path)
+	if err != nil {
+		return Runtime{}, err
+	}
+
+	for _, r := range rs {
+		if r.Is64 {
+			return r, nil
+		}
+	}
+	return rs[0], nil
+}
+
+// RuntimesOfFile returns all the Runtimes an executable file targets.
+// It only ever returns more than one for universal (fat) Mach-O binaries.
+func RuntimesOfFile(path string) (Runtimes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	_, err = io.ReadFull(f, magic[:])
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &UnknownFormatError{Path: path}
+		}
+		return nil, errors.WithStack(err)
+	}
+
+	switch {
+	case bytes.Equal(magic[:], []byte(elf.ELFMAG)):
+		r, err := runtimeOfELF(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading ELF headers of %s", path)
+		}
+		return Runtimes{r}, nil
+	case magic[0] == 'M' && magic[1] == 'Z':
+		r, err := runtimeOfPE(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading PE headers of %s", path)
+		}
+		return Runtimes{r}, nil
+	case isMachOMagic(magic):
+		r, err := runtimeOfMachO(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading Mach-O headers of %s", path)
+		}
+		return Runtimes{r}, nil
+	case isFatMagic(magic) && !isJavaClass(f):
+		rs, err := runtimesOfFatMachO(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading universal Mach-O headers of %s", path)
+		}
+		return rs, nil
+	case magic[0] == '#' && magic[1] == '!':
+		return nil, &ScriptError{Path: path, Interpreter: readInterpreter(f)}
+	}
+
+	return nil, &UnknownFormatError{Path: path}
+}
+
+// ELFInterpreter returns the program interpreter (PT_INTERP) of an ELF
+// executable, usually the dynamic loader, like "/lib/ld-linux-armhf.so.3".
+// Static executables have none, and get an empty string.
+func ELFInterpreter(path string) (string, error) {
+	ef, err := elf.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer ef.Close()
+
+	for _, prog := range ef.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		data, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return "", errors.Wrapf(err, "while reading interpreter of %s", path)
+		}
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+	return "", nil
+}
+
+func runtimeOfELF(r io.ReaderAt) (Runtime, error) {
+	ef, err := elf.NewFile(r)
+	if err != nil {
+		return Runtime{}, err
+	}
+
+	platform := PlatformUnknown
+	switch ef.OSABI {
+	case elf.ELFOSABI_NONE, elf.ELFOSABI_LINUX:
+		platform = PlatformLinux
+	case elf.ELFOSABI_FREEBSD:
+		platform = PlatformFreeBSD
+	}
+
+	is64 := ef.Class == elf.ELFCLASS64
+	arch := ""
+	switch ef.Machine {
+	case elf.EM_X86_64:
+		arch = "amd64"
+	case elf.EM_386:
+		arch = "386"
+	case elf.EM_AARCH64:
+		arch = "arm64"
+	case elf.EM_ARM:
+		arch = "arm"
+	case elf.EM_RISCV:
+		if is64 {
+			arch = "riscv64"
+		}
+	case elf.EM_LOONGARCH:
+		if is64 {
+			arch = "loong64"
+		}
+	case elf.EM_PPC64:
+		if ef.Data == elf.ELFDATA2LSB {
+			arch = "ppc64le"
+		} else {
+			arch = "ppc64"
+		}
+	case elf.EM_S390:
+		if is64 {
+			arch = "s390x"
+		}
+	}
+
+	return Runtime{
+		Platform:     platform,
+		Is64:         is64,
+		Architecture: arch,
+	}, nil
+}
+
+func runtimeOfPE(r io.ReaderAt) (Runtime, error) {
+	pf, err := pe.NewFile(r)
+	if err != nil {
+		return Runtime{}, err
+	}
+
+	arch := ""
+	is64 := false
+	switch pf.Machine {
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		arch, is64 = "amd64", true
+	case pe.IMAGE_FILE_MACHINE_I386:
+		arch = "386"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		arch, is64 = "arm64", true
+	case pe.IMAGE_FILE_MACHINE_ARMNT, pe.IMAGE_FILE_MACHINE_ARM:
+		arch = "arm"
+	}
+
+	return Runtime{
+		Platform:     PlatformWindows,
+		Is64:         is64,
+		Architecture: arch,
+	}, nil
+}
+
+func runtimeOfMachO(r io.ReaderAt) (Runtime, error) {
+	mf, err := macho.NewFile(r)
+	if err != nil {
+		return Runtime{}, err
+	}
+	return machORuntime(mf.Cpu), nil
+}
+
+func runtimesOfFatMachO(r io.ReaderAt) (Runtimes, error) {
+	ff, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer ff.Close()
+
+	var rs Runtimes
+	for _, fa := range ff.Arches {
+		rs = append(rs, machORuntime(fa.Cpu))
+	}
+	return rs, nil
+}
+
+func machORuntime(cpu macho.Cpu) Runtime {
+	arch := ""
+	switch cpu {
+	case macho.CpuAmd64:
+		arch = "amd64"
+	case macho.Cpu386:
+		arch = "386"
+	case macho.CpuArm64:
+		arch = "arm64"
+	case macho.CpuArm:
+		arch = "arm"
+	}
+
+	return Runtime{
+		Platform:     PlatformOSX,
+		Is64:         cpu&0x01000000 != 0, // CPU_ARCH_ABI64
+		Architecture: arch,
+	}
+}
+
+func isMachOMagic(magic [4]byte) bool {
+	// Mach-O magics are stored in the byte order of the target,
+	// which is little-endian for every architecture we care about
+	m := binary.LittleEndian.Uint32(magic[:])
+	return m == macho.Magic32 || m == macho.Magic64
+}
+
+func isFatMagic(magic [4]byte) bool {
+	// universal binaries always have a big-endian header
+	return binary.BigEndian.Uint32(magic[:]) == macho.MagicFat
+}
+
+// Java class files share their magic with universal binaries.
+// Their next field is the class file version (45 and up), whereas
+// universal binaries never have that many slices: file(1) uses the same trick.
+func isJavaClass(r io.ReaderAt) bool {
+	var buf [4]byte
+	_, err := r.ReadAt(buf[:], 4)
+	return err == nil && binary.BigEndian.Uint32(buf[:]) >= 20
+}
+
+func readInterpreter(r io.ReaderAt) string {
+	var buf [256]byte
+	n, _ := r.ReadAt(buf[:], 2)
+	line := string(buf[:n])
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,175 @@
+package ox_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, name string, contents []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	must(os.WriteFile(path, contents, 0o755))
+	return path
+}
+
+func elfHeader(class elf.Class, machine elf.Machine, osabi elf.OSABI) []byte {
+	var ident [16]byte
+	copy(ident[:], elf.ELFMAG)
+	ident[elf.EI_CLASS] = byte(class)
+	ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	ident[elf.EI_OSABI] = byte(osabi)
+
+	buf := new(bytes.Buffer)
+	if class == elf.ELFCLASS64 {
+		must(binary.Write(buf, binary.LittleEndian, elf.Header64{
+			Ident:   ident,
+			Type:    uint16(elf.ET_EXEC),
+			Machine: uint16(machine),
+			Version: uint32(elf.EV_CURRENT),
+			Ehsize:  64,
+		}))
+	} else {
+		must(binary.Write(buf, binary.LittleEndian, elf.Header32{
+			Ident:   ident,
+			Type:    uint16(elf.ET_EXEC),
+			Machine: uint16(machine),
+			Version: uint32(elf.EV_CURRENT),
+			Ehsize:  52,
+		}))
+	}
+	return buf.Bytes()
+}
+
+func peHeader(machine uint16) []byte {
+	buf := new(bytes.Buffer)
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	buf.Write(dos)
+	buf.WriteString("PE\x00\x00")
+	must(binary.Write(buf, binary.LittleEndian, pe.FileHeader{
+		Machine: machine,
+	}))
+	// debug/pe reads at least 96 bytes of DOS header
+	buf.Write(make([]byte, 64))
+	return buf.Bytes()
+}
+
+func machOHeader(cpu macho.Cpu) []byte {
+	buf := new(bytes.Buffer)
+	magic := macho.Magic32
+	if cpu&0x01000000 != 0 {
+		magic = macho.Magic64
+	}
+	must(binary.Write(buf, binary.LittleEndian, macho.FileHeader{
+		Magic: magic,
+		Cpu:   cpu,
+		Type:  macho.TypeExec,
+	}))
+	if magic == macho.Magic64 {
+		// reserved field
+		buf.Write(make([]byte, 4))
+	}
+	return buf.Bytes()
+}
+
+func fatMachO(cpus ...macho.Cpu) []byte {
+	const align = 4096
+	buf := new(bytes.Buffer)
+	must(binary.Write(buf, binary.BigEndian, []uint32{macho.MagicFat, uint32(len(cpus))}))
+	for i, cpu := range cpus {
+		size := uint32(len(machOHeader(cpu)))
+		must(binary.Write(buf, binary.BigEndian, []uint32{uint32(cpu), 0, uint32((i + 1) * align), size, 12}))
+	}
+	for i, cpu := range cpus {
+		buf.Write(make([]byte, (i+1)*align-buf.Len()))
+		buf.Write(machOHeader(cpu))
+	}
+	return buf.Bytes()
+}
+
+func TestRuntimeOfFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents []byte
+		expected ox.Runtime
+	}{
+		{"elf-amd64", elfHeader(elf.ELFCLASS64, elf.EM_X86_64, elf.ELFOSABI_NONE), ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64"}},
+		{"elf-386", elfHeader(elf.ELFCLASS32, elf.EM_386, elf.ELFOSABI_NONE), ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "386"}},
+		{"elf-arm64", elfHeader(elf.ELFCLASS64, elf.EM_AARCH64, elf.ELFOSABI_LINUX), ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}},
+		{"elf-arm", elfHeader(elf.ELFCLASS32, elf.EM_ARM, elf.ELFOSABI_NONE), ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "arm"}},
+		{"elf-riscv64", elfHeader(elf.ELFCLASS64, elf.EM_RISCV, elf.ELFOSABI_NONE), ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "riscv64"}},
+		{"elf-ppc64le", elfHeader(elf.ELFCLASS64, elf.EM_PPC64, elf.ELFOSABI_NONE), ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "ppc64le"}},
+		{"elf-freebsd-amd64", elfHeader(elf.ELFCLASS64, elf.EM_X86_64, elf.ELFOSABI_FREEBSD), ox.Runtime{Platform: ox.PlatformFreeBSD, Is64: true, Architecture: "amd64"}},
+		{"pe-amd64", peHeader(pe.IMAGE_FILE_MACHINE_AMD64), ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "amd64"}},
+		{"pe-386", peHeader(pe.IMAGE_FILE_MACHINE_I386), ox.Runtime{Platform: ox.PlatformWindows, Is64: false, Architecture: "386"}},
+		{"pe-arm64", peHeader(pe.IMAGE_FILE_MACHINE_ARM64), ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "arm64"}},
+		{"macho-amd64", machOHeader(macho.CpuAmd64), ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "amd64"}},
+		{"macho-arm64", machOHeader(macho.CpuArm64), ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "arm64"}},
+		{"macho-386", machOHeader(macho.Cpu386), ox.Runtime{Platform: ox.PlatformOSX, Is64: false, Architecture: "386"}},
+		{"fat-386-amd64", fatMachO(macho.Cpu386, macho.CpuAmd64), ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "amd64"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ox.RuntimeOfFile(writeTestFile(t, tc.name, tc.contents))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, r)
+		})
+	}
+}
+
+func TestRuntimesOfFile_Universal(t *testing.T) {
+	path := writeTestFile(t, "universal", fatMachO(macho.CpuAmd64, macho.CpuArm64))
+
+	rs, err := ox.RuntimesOfFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, ox.Runtimes{
+		{Platform: ox.PlatformOSX, Is64: true, Architecture: "amd64"},
+		{Platform: ox.PlatformOSX, Is64: true, Architecture: "arm64"},
+	}, rs)
+}
+
+func TestRuntimeOfFile_Self(t *testing.T) {
+	exe, err := os.Executable()
+	must(err)
+
+	r, err := ox.RuntimeOfFile(exe)
+	assert.NoError(t, err)
+	assert.Equal(t, runtime.GOARCH, r.Arch(), "test binary should target GOARCH")
+}
+
+func TestRuntimeOfFile_Script(t *testing.T) {
+	path := writeTestFile(t, "launch.sh", []byte("#!/bin/sh -e\necho hi\n"))
+
+	_, err := ox.RuntimeOfFile(path)
+	var se *ox.ScriptError
+	if assert.ErrorAs(t, err, &se) {
+		assert.Equal(t, "/bin/sh -e", se.Interpreter)
+	}
+}
+
+func TestRuntimeOfFile_Unknown(t *testing.T) {
+	for name, contents := range map[string][]byte{
+		"readme.txt":  []byte("hello world"),
+		"empty":       nil,
+		"Main.class":  {0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x34},
+		"truncated.x": {0x7f},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ox.RuntimeOfFile(writeTestFile(t, name, contents))
+			var ufe *ox.UnknownFormatError
+			assert.ErrorAs(t, err, &ufe)
+		})
+	}
+}
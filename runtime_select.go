@@ -0,0 +1,93 @@
+package ox
+
+import "sort"
+
+// Upload traits used by the itch.io backend to flag
+// which platforms an upload is for.
+const (
+	TraitWindows = "p_windows"
+	TraitLinux   = "p_linux"
+	TraitOSX     = "p_osx"
+	TraitAndroid = "p_android"
+)
+
+var traitPlatforms = map[string]Platform{
+	TraitWindows: PlatformWindows,
+	TraitLinux:   PlatformLinux,
+	TraitOSX:     PlatformOSX,
+	TraitAndroid: PlatformAndroid,
+}
+
+// RuntimeForTrait maps an itch.io upload trait (like "p_windows") to
+// a Runtime. Traits don't carry architecture information, so the
+// returned Runtime has an empty Architecture. Returns false if
+// the trait isn't a platform trait.
+func RuntimeForTrait(trait string) (Runtime, bool) {
+	platform, ok := traitPlatforms[trait]
+	if !ok {
+		return Runtime{}, false
+	}
+	return Runtime{Platform: platform}, true
+}
+
+// RuntimesForTraits returns a Runtime for each platform
+// trait in traits, ignoring other traits.
+func RuntimesForTraits(traits []string) Runtimes {
+	var rs Runtimes
+	for _, trait := range traits {
+		if r, ok := RuntimeForTrait(trait); ok {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// RuntimeCandidate is a runtime that a host can run,
+// as returned by Runtimes.BestFor
+type RuntimeCandidate struct {
+	// Index of the runtime in the original slice
+	Index         int
+	Runtime       Runtime
+	Compatibility Compatibility
+	// Score is higher for better candidates
+	Score int
+	// Reason explains why the host can run the candidate
+	Reason string
+}
+
+// compatibilityScores ranks builds that are known to run above
+// those that don't specify an architecture, which might not.
+var compatibilityScores = map[Compatibility]int{
+	CompatibilityNative:   100,
+	CompatibilityEmulated: 75,
+	CompatibilityMultilib: 50,
+	CompatibilityPlatform: 25,
+}
+
+// BestFor returns the runtimes of rs that host can run, best
+// first: native builds, then emulated builds, then 32-bit builds
+// on 64-bit hosts, and finally builds that don't specify an
+// architecture, since there's no telling whether they'll run.
+// Candidates with the same score keep their original order.
+func (rs Runtimes) BestFor(host Runtime) []RuntimeCandidate {
+	var candidates []RuntimeCandidate
+	for i, r := range rs {
+		res := host.Compatibility(r)
+		if !res.OK() {
+			continue
+		}
+
+		candidates = append(candidates, RuntimeCandidate{
+			Index:         i,
+			Runtime:       r,
+			Compatibility: res.Compatibility,
+			Score:         compatibilityScores[res.Compatibility],
+			Reason:        res.Reason,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
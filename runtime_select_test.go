@@ -0,0 +1,82 @@
+package ox_test
+
+import (
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimes_BestFor(t *testing.T) {
+	mustParse := func(slug string) ox.Runtime {
+		r, err := ox.ParseRuntime(slug)
+		must(err)
+		return r
+	}
+
+	uploads := ox.Runtimes{
+		mustParse("windows-amd64"),
+		mustParse("darwin-amd64"),
+		mustParse("linux-386"),
+		mustParse("darwin-arm64"),
+		mustParse("linux-amd64"),
+		mustParse("linux"),
+	}
+
+	t.Run("linux amd64", func(t *testing.T) {
+		candidates := uploads.BestFor(mustParse("linux-amd64"))
+		var indices []int
+		for _, c := range candidates {
+			indices = append(indices, c.Index)
+			assert.NotEmpty(t, c.Reason)
+		}
+		assert.Equal(t, []int{4, 2, 5}, indices)
+		assert.Equal(t, ox.CompatibilityNative, candidates[0].Compatibility)
+		assert.Equal(t, ox.CompatibilityMultilib, candidates[1].Compatibility)
+		assert.Equal(t, ox.CompatibilityPlatform, candidates[2].Compatibility)
+	})
+
+	t.Run("apple silicon", func(t *testing.T) {
+		candidates := uploads.BestFor(mustParse("darwin-arm64"))
+		if assert.Len(t, candidates, 2) {
+			assert.Equal(t, 3, candidates[0].Index)
+			assert.Equal(t, 1, candidates[1].Index)
+			assert.Equal(t, ox.CompatibilityEmulated, candidates[1].Compatibility)
+			assert.True(t, candidates[0].Score > candidates[1].Score)
+		}
+	})
+
+	t.Run("emulated beats unspecified", func(t *testing.T) {
+		rs := append(ox.RuntimesForTraits([]string{"p_osx"}), mustParse("darwin-amd64"))
+		candidates := rs.BestFor(mustParse("darwin-arm64"))
+		if assert.Len(t, candidates, 2) {
+			assert.Equal(t, ox.CompatibilityEmulated, candidates[0].Compatibility)
+			assert.Equal(t, ox.CompatibilityPlatform, candidates[1].Compatibility)
+		}
+	})
+
+	t.Run("windows on arm", func(t *testing.T) {
+		candidates := uploads.BestFor(mustParse("windows-arm64"))
+		if assert.Len(t, candidates, 1) {
+			assert.Equal(t, 0, candidates[0].Index)
+			assert.Equal(t, ox.CompatibilityEmulated, candidates[0].Compatibility)
+		}
+	})
+
+	t.Run("nothing compatible", func(t *testing.T) {
+		assert.Empty(t, ox.Runtimes{mustParse("linux-arm64")}.BestFor(mustParse("windows-amd64")))
+	})
+}
+
+func TestRuntimesForTraits(t *testing.T) {
+	rs := ox.RuntimesForTraits([]string{"p_windows", "demo", "p_linux", "p_osx", "p_android"})
+	assert.Equal(t, ox.Runtimes{
+		{Platform: ox.PlatformWindows},
+		{Platform: ox.PlatformLinux},
+		{Platform: ox.PlatformOSX},
+		{Platform: ox.PlatformAndroid},
+	}, rs)
+
+	_, ok := ox.RuntimeForTrait("can_be_bought")
+	assert.False(t, ok)
+}
@@ -20,6 +20,10 @@ func TestCurrentRuntime_Platform(t *testing.T) {
 		expectedPlatform = ox.PlatformOSX
 	case "windows":
 		expectedPlatform = ox.PlatformWindows
+	case "android":
+		expectedPlatform = ox.PlatformAndroid
+	case "freebsd":
+		expectedPlatform = ox.PlatformFreeBSD
 	default:
 		expectedPlatform = ox.PlatformUnknown
 	}
@@ -41,9 +45,9 @@ func TestCurrentRuntime_Is64(t *testing.T) {
 	r := ox.CurrentRuntime()
 
 	switch runtime.GOARCH {
-	case "amd64", "arm64":
+	case "amd64", "arm64", "riscv64", "loong64", "ppc64le", "ppc64", "s390x", "mips64", "mips64le":
 		assert.True(t, r.Is64, "Is64 should be true for %s", runtime.GOARCH)
-	case "386", "arm":
+	case "386", "arm", "mips", "mipsle":
 		assert.False(t, r.Is64, "Is64 should be false for %s", runtime.GOARCH)
 	default:
 		t.Logf("Unknown GOARCH: %s, skipping Is64 assertion", runtime.GOARCH)
@@ -70,6 +74,12 @@ func TestArchMapping(t *testing.T) {
 		// 32-bit ARM variants
 		{"armv7l", "arm"},
 		{"armv6l", "arm"},
+		{"armv8l", "arm"},
+		// other 64-bit architectures
+		{"riscv64", "riscv64"},
+		{"loongarch64", "loong64"},
+		{"ppc64le", "ppc64le"},
+		{"s390x", "s390x"},
 	}
 
 	for _, tc := range tests {
@@ -95,6 +105,8 @@ func TestRuntime_OS(t *testing.T) {
 		{ox.PlatformLinux, "linux"},
 		{ox.PlatformOSX, "darwin"},
 		{ox.PlatformWindows, "windows"},
+		{ox.PlatformAndroid, "android"},
+		{ox.PlatformFreeBSD, "freebsd"},
 		{ox.PlatformUnknown, "unknown"},
 	}
 
@@ -118,6 +130,8 @@ func TestRuntime_String(t *testing.T) {
 		{ox.Runtime{Platform: ox.PlatformWindows, Is64: false}, "32-bit Windows"},
 		{ox.Runtime{Platform: ox.PlatformLinux, Is64: true}, "64-bit Linux"},
 		{ox.Runtime{Platform: ox.PlatformLinux, Is64: false}, "32-bit Linux"},
+		{ox.Runtime{Platform: ox.PlatformFreeBSD, Is64: true}, "64-bit FreeBSD"},
+		{ox.Runtime{Platform: ox.PlatformAndroid, Is64: false}, "32-bit Android"},
 		{ox.Runtime{Platform: ox.PlatformUnknown, Is64: true}, "64-bit Unknown"},
 	}
 
@@ -149,6 +163,19 @@ func TestRuntime_Equals(t *testing.T) {
 	assert.False(t, r1.Equals(r5), "Runtimes with different Is64 should not be equal")
 }
 
+// TestRuntime_StrictEquals tests the StrictEquals() method
+func TestRuntime_StrictEquals(t *testing.T) {
+	amd64 := ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64"}
+	arm64 := ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}
+	legacy := ox.Runtime{Platform: ox.PlatformLinux, Is64: true}
+
+	assert.True(t, amd64.StrictEquals(amd64), "Identical runtimes should be equal")
+	assert.False(t, amd64.StrictEquals(arm64), "Runtimes with different Architecture should not be equal")
+	assert.True(t, amd64.StrictEquals(legacy), "Legacy 64-bit runtimes are amd64")
+	assert.False(t, arm64.StrictEquals(legacy), "Legacy 64-bit runtimes are not arm64")
+	assert.False(t, amd64.StrictEquals(ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "amd64"}), "Runtimes with different Platform should not be equal")
+}
+
 // TestRuntime_Arch_Fallback tests that Arch() falls back correctly when Architecture field is empty
 func TestRuntime_Arch_Fallback(t *testing.T) {
 	// Test fallback for 64-bit (should return "amd64")
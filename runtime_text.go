@@ -0,0 +1,188 @@
+package ox
+
+import (
+	"encoding"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var _ encoding.TextMarshaler = Runtime{}
+var _ encoding.TextUnmarshaler = (*Runtime)(nil)
+var _ json.Marshaler = Runtime{}
+var _ json.Unmarshaler = (*Runtime)(nil)
+
+// ParsePlatform parses a platform name. It accepts itch.io platform
+// names ("osx", "windows", "linux") as well as GOOS names ("darwin").
+func ParsePlatform(s string) (Platform, error) {
+	switch strings.ToLower(s) {
+	case "osx", "darwin", "macos":
+		return PlatformOSX, nil
+	case "windows":
+		return PlatformWindows, nil
+	case "linux":
+		return PlatformLinux, nil
+	case "android":
+		return PlatformAndroid, nil
+	case "freebsd":
+		return PlatformFreeBSD, nil
+	case "unknown":
+		return PlatformUnknown, nil
+	}
+	return "", errors.Errorf("unknown platform %q", s)
+}
+
+// Architecture segments of slugs for runtimes that only
+// know their word size, like legacy records without an Architecture
+const (
+	slugAny32 = "32bit"
+	slugAny64 = "64bit"
+)
+
+// ParseRuntime parses a runtime slug, as returned by Runtime.Slug,
+// like "linux-arm64" or "windows-386". Platforms can be given in GOOS or
+// itch.io format, and architectures in GOARCH format or any of the names
+// known to MapArchitecture ("x86_64", "aarch64", etc.). Both '-' and '/'
+// are accepted as separators.
+//
+// Unknown platforms and architectures are kept as-is, so that slugs
+// written by newer versions survive a round-trip. Unknown architectures
+// are assumed to be 64-bit if their name ends in "64".
+//
+// "linux-64bit" and "linux-32bit" parse into a Runtime with an empty
+// Architecture, as does "linux" alone, for 32-bit. A trailing separator,
+// like "linux-", is an error.
+func ParseRuntime(s string) (Runtime, error) {
+	platformPart, archPart := s, ""
+	if i := strings.IndexAny(s, "-/"); i >= 0 {
+		platformPart, archPart = s[:i], s[i+1:]
+		if archPart == "" {
+			return Runtime{}, errors.Errorf("while parsing runtime %q: empty architecture", s)
+		}
+	}
+	if platformPart == "" {
+		return Runtime{}, errors.Errorf("while parsing runtime %q: empty platform", s)
+	}
+
+	platform, err := ParsePlatform(platformPart)
+	if err != nil {
+		platform = Platform(platformPart)
+	}
+
+	switch archPart {
+	case "", slugAny32:
+		return Runtime{Platform: platform}, nil
+	case slugAny64:
+		return Runtime{Platform: platform, Is64: true}, nil
+	}
+
+	arch := archPart
+	if _, ok := archWordSizes[arch]; !ok {
+		if mapped := MapArchitecture(archPart); mapped != "" {
+			arch = mapped
+		}
+	}
+
+	is64 := isArch64(arch)
+	if _, ok := archWordSizes[arch]; !ok {
+		is64 = strings.HasSuffix(arch, "64")
+	}
+
+	return Runtime{
+		Platform:     platform,
+		Is64:         is64,
+		Architecture: arch,
+	}, nil
+}
+
+// Slug returns a canonical, parseable representation of r,
+// in GOOS-GOARCH format, like "darwin-arm64". Unknown platforms and
+// architectures are written as-is. Runtimes without an Architecture
+// are formatted according to Is64, as "linux-64bit" or "linux-32bit".
+func (r Runtime) Slug() string {
+	os := r.OS()
+	if os == "unknown" && r.Platform != "" {
+		os = string(r.Platform)
+	}
+
+	arch := r.Architecture
+	if arch == "" {
+		arch = slugAny32
+		if r.Is64 {
+			arch = slugAny64
+		}
+	}
+	return os + "-" + arch
+}
+
+// MarshalText formats r as its slug. It lets Runtime be
+// used as a map key in JSON, or as a command-line flag value.
+func (r Runtime) MarshalText() ([]byte, error) {
+	return []byte(r.Slug()), nil
+}
+
+// UnmarshalText parses a slug, see ParseRuntime.
+func (r *Runtime) UnmarshalText(text []byte) error {
+	parsed, err := ParseRuntime(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// runtimeFields has the same fields as Runtime, without its methods,
+// so it gets marshalled as a regular JSON object.
+type runtimeFields Runtime
+
+// RuntimeJSONVersion is the version of the JSON format written by
+// Runtime.MarshalJSON. Version 1 records (with no "v" field) predate
+// the "arch" field, and only have "platform" and "is64".
+const RuntimeJSONVersion = 2
+
+type runtimeJSON struct {
+	runtimeFields
+	Version int `json:"v,omitempty"`
+}
+
+// MarshalJSON keeps Runtime marshalled as an object, rather than
+// a slug (which MarshalText would otherwise cause), and tags it
+// with RuntimeJSONVersion.
+func (r Runtime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(runtimeJSON{
+		runtimeFields: runtimeFields(r),
+		Version:       RuntimeJSONVersion,
+	})
+}
+
+// UnmarshalJSON accepts slugs (see ParseRuntime), and objects.
+//
+// Legacy objects, written by older clients as {"platform", "is64"},
+// are upgraded by inferring Architecture from Is64: "amd64" for 64-bit
+// and "386" for 32-bit, since those were the only architectures supported
+// at the time. Objects that carry "arch" are kept as-is, and so are
+// unknown platforms and architectures.
+func (r *Runtime) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		return r.UnmarshalText([]byte(s))
+	}
+
+	var rj runtimeJSON
+	err := json.Unmarshal(data, &rj)
+	if err != nil {
+		return err
+	}
+
+	parsed := Runtime(rj.runtimeFields)
+	if rj.Version < 2 && parsed.Architecture == "" {
+		parsed.Architecture = parsed.Arch()
+	}
+	*r = parsed
+	return nil
+}
@@ -0,0 +1,184 @@
+package ox_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRuntime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ox.Runtime
+	}{
+		{"linux-arm64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}},
+		{"linux/amd64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64"}},
+		{"darwin-arm64", ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "arm64"}},
+		{"osx-amd64", ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "amd64"}},
+		{"windows-386", ox.Runtime{Platform: ox.PlatformWindows, Is64: false, Architecture: "386"}},
+		{"windows-x86", ox.Runtime{Platform: ox.PlatformWindows, Is64: false, Architecture: "386"}},
+		{"linux-x86_64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64"}},
+		{"linux-aarch64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}},
+		{"linux-armv7l", ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "arm"}},
+		{"linux", ox.Runtime{Platform: ox.PlatformLinux}},
+		{"linux-32bit", ox.Runtime{Platform: ox.PlatformLinux}},
+		{"windows-64bit", ox.Runtime{Platform: ox.PlatformWindows, Is64: true}},
+		{"haiku-amd64", ox.Runtime{Platform: "haiku", Is64: true, Architecture: "amd64"}},
+		{"linux-wasm64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "wasm64"}},
+		{"linux-vax", ox.Runtime{Platform: ox.PlatformLinux, Is64: false, Architecture: "vax"}},
+		{"linux-riscv64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "riscv64"}},
+		{"linux-loongarch64", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "loong64"}},
+		{"linux-ppc64le", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "ppc64le"}},
+		{"linux-s390x", ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "s390x"}},
+		{"freebsd-amd64", ox.Runtime{Platform: ox.PlatformFreeBSD, Is64: true, Architecture: "amd64"}},
+		{"android-arm64", ox.Runtime{Platform: ox.PlatformAndroid, Is64: true, Architecture: "arm64"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			r, err := ox.ParseRuntime(tc.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, r)
+		})
+	}
+}
+
+func TestParseRuntime_Invalid(t *testing.T) {
+	for _, input := range []string{"", "-amd64", "/", "linux-", "linux/"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ox.ParseRuntime(input)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRuntime_Slug_RoundTrip(t *testing.T) {
+	for _, slug := range []string{"linux-amd64", "linux-arm", "darwin-arm64", "windows-386", "linux-64bit", "windows-32bit", "haiku-wasm64", "unknown-arm64", "linux-vax"} {
+		t.Run(slug, func(t *testing.T) {
+			r, err := ox.ParseRuntime(slug)
+			assert.NoError(t, err)
+			assert.Equal(t, slug, r.Slug())
+		})
+	}
+}
+
+func TestRuntime_Slug_NoArchitecture(t *testing.T) {
+	// legacy runtimes only have Is64, which must not get lost,
+	// nor turn into an Architecture
+	r64 := ox.Runtime{Platform: ox.PlatformLinux, Is64: true}
+	r32 := ox.Runtime{Platform: ox.PlatformLinux, Is64: false}
+	assert.Equal(t, "linux-64bit", r64.Slug())
+	assert.Equal(t, "linux-32bit", r32.Slug())
+
+	for _, r := range []ox.Runtime{r64, r32} {
+		var decoded ox.Runtime
+		text, err := r.MarshalText()
+		assert.NoError(t, err)
+		assert.NoError(t, decoded.UnmarshalText(text))
+		assert.Equal(t, r, decoded)
+	}
+
+	m := map[ox.Runtime]string{r64: "64-bit", r32: "32-bit"}
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"linux-64bit":"64-bit","linux-32bit":"32-bit"}`, string(data))
+}
+
+func TestRuntime_Slug_UnknownValues(t *testing.T) {
+	for _, r := range []ox.Runtime{
+		{Platform: "haiku", Is64: true, Architecture: "wasm64"},
+		{Platform: ox.PlatformLinux, Is64: false, Architecture: "vax"},
+		{Platform: ox.PlatformUnknown, Is64: true, Architecture: "arm64"},
+	} {
+		var decoded ox.Runtime
+		text, err := r.MarshalText()
+		assert.NoError(t, err)
+		assert.NoError(t, decoded.UnmarshalText(text), "%s", text)
+		assert.Equal(t, r, decoded)
+	}
+}
+
+func TestRuntime_JSON(t *testing.T) {
+	r := ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}
+
+	// as a value, Runtime stays an object
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"platform":"linux","is64":true,"arch":"arm64","v":2}`, string(data))
+
+	var decoded ox.Runtime
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, r, decoded)
+
+	// slugs are accepted too
+	assert.NoError(t, json.Unmarshal([]byte(`"windows-amd64"`), &decoded))
+	assert.Equal(t, ox.Runtime{Platform: ox.PlatformWindows, Is64: true, Architecture: "amd64"}, decoded)
+
+	// as a map key, Runtime is a slug
+	m := map[ox.Runtime]string{r: "build.tar.gz"}
+	data, err = json.Marshal(m)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"linux-arm64":"build.tar.gz"}`, string(data))
+
+	var decodedMap map[ox.Runtime]string
+	assert.NoError(t, json.Unmarshal(data, &decodedMap))
+	assert.Equal(t, m, decodedMap)
+}
+
+func TestRuntime_JSON_UnknownValues(t *testing.T) {
+	input := `{"platform":"haiku","is64":true,"arch":"wasm64","v":2}`
+
+	var r ox.Runtime
+	assert.NoError(t, json.Unmarshal([]byte(input), &r))
+	assert.Equal(t, ox.Runtime{Platform: "haiku", Is64: true, Architecture: "wasm64"}, r)
+
+	data, err := json.Marshal(r)
+	assert.NoError(t, err)
+	assert.JSONEq(t, input, string(data))
+
+	// as map keys, they go through slugs, and must survive too
+	m := map[ox.Runtime]int{
+		r: 1,
+		{Platform: ox.PlatformUnknown, Is64: true, Architecture: "wasm64"}: 2,
+		{Platform: ox.PlatformLinux, Is64: false, Architecture: "vax"}:     3,
+	}
+	data, err = json.Marshal(m)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"haiku-wasm64":1,"unknown-wasm64":2,"linux-vax":3}`, string(data))
+
+	var decodedMap map[ox.Runtime]int
+	assert.NoError(t, json.Unmarshal(data, &decodedMap))
+	assert.Equal(t, m, decodedMap)
+}
+
+func TestRuntime_JSON_Versions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ox.Runtime
+	}{
+		{"legacy 64-bit", `{"platform":"linux","is64":true}`, ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "amd64"}},
+		{"legacy 32-bit", `{"platform":"windows","is64":false}`, ox.Runtime{Platform: ox.PlatformWindows, Is64: false, Architecture: "386"}},
+		{"unversioned with arch", `{"platform":"linux","is64":true,"arch":"arm64"}`, ox.Runtime{Platform: ox.PlatformLinux, Is64: true, Architecture: "arm64"}},
+		{"v2", `{"platform":"osx","is64":true,"arch":"arm64","v":2}`, ox.Runtime{Platform: ox.PlatformOSX, Is64: true, Architecture: "arm64"}},
+		{"v2 without arch", `{"platform":"linux","is64":false,"v":2}`, ox.Runtime{Platform: ox.PlatformLinux}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var r ox.Runtime
+			assert.NoError(t, json.Unmarshal([]byte(tc.input), &r))
+			assert.Equal(t, tc.expected, r)
+
+			// re-encoding always yields the current version
+			data, err := json.Marshal(r)
+			assert.NoError(t, err)
+			var again ox.Runtime
+			assert.NoError(t, json.Unmarshal(data, &again))
+			assert.Equal(t, r, again)
+			assert.Contains(t, string(data), `"v":2`)
+		})
+	}
+}
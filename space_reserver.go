@@ -0,0 +1,212 @@
+package ox
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SpaceReserver keeps track of disk space promised to operations
+// that haven't used it yet, like preallocations running in parallel,
+// so that together they don't overcommit a filesystem.
+// Reservations are tracked per filesystem, by device ID.
+type SpaceReserver struct {
+	// Margin is how many bytes to always leave free on each filesystem
+	Margin uint64
+
+	// DiskFreeSpace defaults to ox.DiskFreeSpace
+	DiskFreeSpace func(path string) (DiskSpace, error)
+	// DeviceID defaults to ox.DeviceID
+	DeviceID func(path string) (uint64, error)
+
+	mu       sync.Mutex
+	reserved map[uint64]uint64
+}
+
+// NewSpaceReserver returns a SpaceReserver that leaves
+// margin bytes free on every filesystem.
+func NewSpaceReserver(margin uint64) *SpaceReserver {
+	return &SpaceReserver{
+		Margin:        margin,
+		DiskFreeSpace: DiskFreeSpace,
+		DeviceID:      DeviceID,
+	}
+}
+
+// SpaceReservation is space set aside by SpaceReserver.Reserve.
+// It must be released once the space is actually used, or not needed.
+type SpaceReservation struct {
+	// Device is the device ID of the filesystem
+	Device uint64
+	// Size is the number of bytes reserved. It only changes
+	// while SpaceReserver.Preallocate runs, or on Release.
+	Size uint64
+
+	sr *SpaceReserver
+}
+
+// Release gives the reserved space back. It's safe to call it
+// more than once, or on a nil reservation.
+func (r *SpaceReservation) Release() {
+	if r == nil {
+		return
+	}
+	r.shrink(0)
+}
+
+// shrink gives back all but size bytes of the reservation
+func (r *SpaceReservation) shrink(size uint64) {
+	sr := r.sr
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if size >= r.Size {
+		return
+	}
+	sr.reserved[r.Device] = saturatingSub(sr.reserved[r.Device], r.Size-size)
+	if sr.reserved[r.Device] == 0 {
+		delete(sr.reserved, r.Device)
+	}
+	r.Size = size
+}
+
+// Reserve sets size bytes aside on the filesystem path is on. path
+// doesn't need to exist yet. It returns an InsufficientSpaceError
+// if the space available, minus what is already reserved and the
+// margin, is less than size.
+func (sr *SpaceReserver) Reserve(path string, size uint64) (*SpaceReservation, error) {
+	existing, err := existingAncestor(path)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := sr.deviceID(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := sr.diskFreeSpace(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	unreserved := saturatingSub(ds.Available, sr.reserved[device]+sr.Margin)
+	if size > unreserved {
+		return nil, errors.WithStack(&InsufficientSpaceError{
+			Path:      path,
+			Required:  size,
+			Available: unreserved,
+		})
+	}
+
+	if sr.reserved == nil {
+		sr.reserved = make(map[uint64]uint64)
+	}
+	sr.reserved[device] += size
+
+	return &SpaceReservation{
+		Device: device,
+		Size:   size,
+		sr:     sr,
+	}, nil
+}
+
+// Reserved returns how many bytes are currently reserved
+// on the filesystem path is on.
+func (sr *SpaceReserver) Reserved(path string) (uint64, error) {
+	existing, err := existingAncestor(path)
+	if err != nil {
+		return 0, err
+	}
+
+	device, err := sr.deviceID(existing)
+	if err != nil {
+		return 0, err
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.reserved[device], nil
+}
+
+// Preallocate reserves the space needed to grow f to size bytes, and
+// preallocates it. As preallocation progresses, the filesystem starts
+// accounting for the space itself, so the reservation shrinks by
+// as much, and whatever is left of it is released at the end.
+func (sr *SpaceReserver) Preallocate(f *os.File, size int64, opts PreallocateOptions) (*PreallocateResult, error) {
+	currentSize, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if size > currentSize && opts.Strategy != PreallocateSparse {
+		reservation, err := sr.Reserve(f.Name(), uint64(size-currentSize))
+		if err != nil {
+			return nil, err
+		}
+		defer reservation.Release()
+
+		progress := opts.Progress
+		opts.Progress = func(done int64, total int64) {
+			reservation.shrink(uint64(total - done))
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+	}
+
+	return PreallocateWithOptions(f, size, opts)
+}
+
+func (sr *SpaceReserver) deviceID(path string) (uint64, error) {
+	if sr.DeviceID != nil {
+		return sr.DeviceID(path)
+	}
+	return DeviceID(path)
+}
+
+func (sr *SpaceReserver) diskFreeSpace(path string) (DiskSpace, error) {
+	if sr.DiskFreeSpace != nil {
+		return sr.DiskFreeSpace(path)
+	}
+	return DiskFreeSpace(path)
+}
+
+// existingAncestor returns path if it exists, or its closest
+// parent that does, so files can be reserved for before they're
+// created.
+func existingAncestor(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	for {
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", errors.WithStack(err)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", errors.WithStack(err)
+		}
+		path = parent
+	}
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
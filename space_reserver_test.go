@@ -0,0 +1,202 @@
+package ox_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/itchio/ox"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDisks pretends every path inside of otherDisk is on
+// device 2, and everything else on device 1
+func fakeDisks(available map[uint64]uint64, otherDisk string) *ox.SpaceReserver {
+	deviceID := func(path string) (uint64, error) {
+		if otherDisk != "" && strings.HasPrefix(path, otherDisk) {
+			return 2, nil
+		}
+		return 1, nil
+	}
+
+	sr := ox.NewSpaceReserver(100)
+	sr.DeviceID = deviceID
+	sr.DiskFreeSpace = func(path string) (ox.DiskSpace, error) {
+		device, _ := deviceID(path)
+		return ox.DiskSpace{
+			Free:      available[device],
+			Available: available[device],
+			Total:     10000,
+		}, nil
+	}
+	return sr
+}
+
+func Test_SpaceReserver(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	must(err)
+	defer os.RemoveAll(dir)
+
+	sr := fakeDisks(map[uint64]uint64{1: 1000}, "")
+
+	// files don't need to exist yet
+	a, err := sr.Reserve(filepath.Join(dir, "a", "b", "c.dat"), 600)
+	must(err)
+	assert.EqualValues(600, a.Size)
+
+	reserved, err := sr.Reserved(dir)
+	must(err)
+	assert.EqualValues(600, reserved)
+
+	// 1000 available - 600 reserved - 100 margin = 300
+	_, err = sr.Reserve(filepath.Join(dir, "d.dat"), 301)
+	assert.True(errors.Is(err, ox.ErrInsufficientSpace))
+	var ise *ox.InsufficientSpaceError
+	if assert.True(errors.As(err, &ise)) {
+		assert.EqualValues(300, ise.Available)
+	}
+
+	b, err := sr.Reserve(filepath.Join(dir, "d.dat"), 300)
+	must(err)
+
+	a.Release()
+	a.Release()
+	reserved, err = sr.Reserved(dir)
+	must(err)
+	assert.EqualValues(300, reserved)
+
+	b.Release()
+	reserved, err = sr.Reserved(dir)
+	must(err)
+	assert.EqualValues(0, reserved)
+}
+
+func Test_SpaceReserverPerDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	dirA, err := ioutil.TempDir("", "")
+	must(err)
+	defer os.RemoveAll(dirA)
+	dirB, err := ioutil.TempDir("", "")
+	must(err)
+	defer os.RemoveAll(dirB)
+
+	sr := fakeDisks(map[uint64]uint64{1: 1000, 2: 1000}, dirB)
+
+	_, err = sr.Reserve(filepath.Join(dirA, "game.zip"), 900)
+	must(err)
+
+	// other device isn't affected
+	_, err = sr.Reserve(filepath.Join(dirB, "game.zip"), 900)
+	must(err)
+
+	_, err = sr.Reserve(filepath.Join(dirA, "other.zip"), 1)
+	assert.Error(err)
+}
+
+func Test_SpaceReserverConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	sr := fakeDisks(map[uint64]uint64{1: 10100}, "")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var granted int
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := sr.Reserve(os.TempDir(), 1000); err == nil {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(10, granted)
+}
+
+func Test_SpaceReserverPreallocate(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	sr := ox.NewSpaceReserver(0)
+	res, err := sr.Preallocate(f, 4096, ox.PreallocateOptions{})
+	must(err)
+	assert.NotEmpty(res.Strategy)
+
+	s, err := f.Stat()
+	must(err)
+	assert.EqualValues(4096, s.Size())
+
+	reserved, err := sr.Reserved(f.Name())
+	must(err)
+	assert.EqualValues(0, reserved, "reservation should be released afterwards")
+
+	ds, err := ox.DiskFreeSpace(f.Name())
+	must(err)
+	_, err = sr.Preallocate(f, 4096+int64(ds.Available)+1024*1024*1024, ox.PreallocateOptions{})
+	assert.True(errors.Is(err, ox.ErrInsufficientSpace))
+}
+
+func Test_SpaceReserverPreallocateShrinks(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	must(err)
+	defer f.Close()
+	defer os.Remove(f.Name())
+
+	// the fake disk fills up as the file grows
+	capacity := uint64(20 * mib)
+	sr := ox.NewSpaceReserver(0)
+	sr.DeviceID = func(path string) (uint64, error) {
+		return 1, nil
+	}
+	sr.DiskFreeSpace = func(path string) (ox.DiskSpace, error) {
+		s, err := f.Stat()
+		if err != nil {
+			return ox.DiskSpace{}, err
+		}
+		available := capacity - uint64(s.Size())
+		return ox.DiskSpace{Free: available, Available: available, Total: capacity}, nil
+	}
+
+	var reservedErr error
+	reserved := false
+	_, err = sr.Preallocate(f, 12*mib, ox.PreallocateOptions{
+		Strategy:            ox.PreallocateZeroFill,
+		ZeroFillConcurrency: 1,
+		Progress: func(done int64, total int64) {
+			if done < 8*mib || reserved {
+				return
+			}
+			reserved = true
+
+			// 20 - 8 written - 4 still reserved = 8 left, or if the
+			// last chunk is already written, 20 - 12 - 4 = 4 left.
+			// Counting written bytes twice would leave nothing.
+			var r *ox.SpaceReservation
+			r, reservedErr = sr.Reserve(f.Name(), 4*mib)
+			r.Release()
+		},
+	})
+	must(err)
+	assert.True(reserved)
+	assert.NoError(reservedErr, "written bytes shouldn't be counted twice")
+
+	left, err := sr.Reserved(f.Name())
+	must(err)
+	assert.EqualValues(0, left)
+}